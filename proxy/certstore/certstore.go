@@ -0,0 +1,220 @@
+package certstore
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Pair is a path to certificate and private key files
+type Pair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// ParsePair parses pair from "cert.pem,key.pem"
+func ParsePair(pair string) (Pair, error) {
+	files := strings.Split(pair, ",")
+	if len(files) != 2 || strings.TrimSpace(files[0]) == "" || strings.TrimSpace(files[1]) == "" {
+		return Pair{}, fmt.Errorf("Invalid certificate pair %q, expected \"cert.pem,key.pem\"", pair)
+	}
+
+	return Pair{CertFile: strings.TrimSpace(files[0]), KeyFile: strings.TrimSpace(files[1])}, nil
+}
+
+// loadedPair is a certificate, loaded from the pair, with modification times of files
+type loadedPair struct {
+	pair            Pair
+	certificate     *tls.Certificate
+	certFileModTime time.Time
+	keyFileModTime  time.Time
+}
+
+// Store keeps certificates of pairs, selects them by SNI of TLS handshake,
+// and loads renewed certificates when files of pairs are modified
+type Store struct {
+	lock          sync.RWMutex
+	pairs         []*loadedPair
+	checkInterval time.Duration
+	watchStop     chan struct{}
+	watchDone     sync.WaitGroup
+}
+
+// NewStore loads certificates of pairs. Files are checked for modification every checkInterval
+func NewStore(pairs []Pair, checkInterval time.Duration) (*Store, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("No certificate pairs")
+	}
+
+	store := &Store{
+		pairs:         make([]*loadedPair, len(pairs)),
+		checkInterval: checkInterval,
+	}
+
+	for i, pair := range pairs {
+		loaded, err := loadPair(pair)
+		if err != nil {
+			return nil, err
+		}
+
+		store.pairs[i] = loaded
+	}
+
+	return store, nil
+}
+
+// loadPair loads certificate and private key, and parses certificate to match it with SNI
+func loadPair(pair Pair) (*loadedPair, error) {
+	certFileInfo, err := os.Stat(pair.CertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFileInfo, err := os.Stat(pair.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot load certificate %s: %v", pair.CertFile, err)
+	}
+
+	certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse certificate %s: %v", pair.CertFile, err)
+	}
+
+	return &loadedPair{
+		pair:            pair,
+		certificate:     &certificate,
+		certFileModTime: certFileInfo.ModTime(),
+		keyFileModTime:  keyFileInfo.ModTime(),
+	}, nil
+}
+
+// GetCertificate returns the first certificate, supported by client and matching its SNI.
+// If none matches, the first certificate is returned
+func (store *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	for _, loaded := range store.pairs {
+		if hello.SupportsCertificate(loaded.certificate) == nil {
+			return loaded.certificate, nil
+		}
+	}
+
+	return store.pairs[0].certificate, nil
+}
+
+// Reload loads certificates of pairs with modified files.
+// If renewed files are invalid, like when they are partially written, previous certificate is kept
+func (store *Store) Reload() {
+	for i, pair := range store.pairs {
+		certFileInfo, certErr := os.Stat(pair.pair.CertFile)
+		keyFileInfo, keyErr := os.Stat(pair.pair.KeyFile)
+		if certErr != nil || keyErr != nil {
+			log.WithFields(log.Fields{"Certificate": pair.pair.CertFile, "Key": pair.pair.KeyFile}).Error("Cannot check certificate files")
+			continue
+		}
+
+		if certFileInfo.ModTime().Equal(pair.certFileModTime) && keyFileInfo.ModTime().Equal(pair.keyFileModTime) {
+			continue
+		}
+
+		loaded, err := loadPair(pair.pair)
+		if err != nil {
+			log.WithFields(log.Fields{"Error": err}).Error("Cannot reload certificate")
+			continue
+		}
+
+		store.lock.Lock()
+		store.pairs[i] = loaded
+		store.lock.Unlock()
+
+		log.WithFields(log.Fields{"Certificate": pair.pair.CertFile, "NotAfter": loaded.certificate.Leaf.NotAfter}).Info("Certificate reloaded")
+	}
+}
+
+// Watch starts checking files of pairs for modification
+func (store *Store) Watch() {
+	if store.checkInterval <= 0 {
+		return
+	}
+
+	store.watchStop = make(chan struct{})
+	store.watchDone.Add(1)
+
+	go func(ticker *time.Ticker, stop chan struct{}) {
+		defer store.watchDone.Done()
+
+		for {
+			select {
+			case <-ticker.C:
+				store.Reload()
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}(time.NewTicker(store.checkInterval), store.watchStop)
+}
+
+// Close stops checking files of pairs
+func (store *Store) Close() {
+	if store.watchStop == nil {
+		return
+	}
+
+	close(store.watchStop)
+	store.watchDone.Wait()
+	store.watchStop = nil
+}
+
+// Client authentication modes of TLS listener
+const (
+	ClientAuthRequest       = "request"
+	ClientAuthRequire       = "require"
+	ClientAuthVerifyIfGiven = "verify-if-given"
+)
+
+// ParseClientAuth parses client authentication mode.
+// "request" asks for certificate and accepts clients without it or with invalid one,
+// "require" accepts only clients with valid certificate,
+// "verify-if-given" accepts clients without certificate and rejects invalid ones
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case ClientAuthRequest:
+		return tls.RequestClientCert, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("Invalid client authentication mode %q", mode)
+	}
+}
+
+// LoadCertPool loads bundle of PEM encoded CA certificates
+func LoadCertPool(bundleFile string) (*x509.CertPool, error) {
+	bundle, err := ioutil.ReadFile(bundleFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("No certificates in CA bundle %s", bundleFile)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,161 @@
+package certstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestPair writes self-signed certificate for dnsName and its key into directory
+func writeTestPair(t *testing.T, directory string, dnsName string, serialNumber int64) Pair {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	require.NoError(t, err)
+
+	key, err := x509.MarshalECPrivateKey(privateKey)
+	require.NoError(t, err)
+
+	pair := Pair{
+		CertFile: filepath.Join(directory, dnsName+".pem"),
+		KeyFile:  filepath.Join(directory, dnsName+".key"),
+	}
+
+	require.NoError(t, ioutil.WriteFile(pair.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0600))
+	require.NoError(t, ioutil.WriteFile(pair.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600))
+
+	return pair
+}
+
+// handshake connects to TLS server with store by server name, and returns certificate of server
+func handshake(t *testing.T, store *Store, serverName string) *x509.Certificate {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		defer serverConn.Close()
+		tls.Server(serverConn, &tls.Config{GetCertificate: store.GetCertificate}).Handshake()
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	require.NoError(t, client.Handshake())
+
+	return client.ConnectionState().PeerCertificates[0]
+}
+
+func TestParsePair(t *testing.T) {
+	require := require.New(t)
+
+	pair, err := ParsePair("/certs/cert.pem, /certs/key.pem")
+	require.NoError(err)
+	require.Equal(Pair{CertFile: "/certs/cert.pem", KeyFile: "/certs/key.pem"}, pair)
+
+	for _, invalidPair := range []string{"/certs/cert.pem", "/certs/cert.pem,", "a,b,c"} {
+		_, err := ParsePair(invalidPair)
+		require.Error(err, invalidPair)
+	}
+}
+
+func TestStoreSelectsCertificateBySNI(t *testing.T) {
+	require := require.New(t)
+
+	directory := t.TempDir()
+	store, err := NewStore([]Pair{
+		writeTestPair(t, directory, "a.example.com", 1),
+		writeTestPair(t, directory, "b.example.com", 2),
+	}, 0)
+	require.NoError(err)
+
+	require.Equal("a.example.com", handshake(t, store, "a.example.com").Subject.CommonName)
+	require.Equal("b.example.com", handshake(t, store, "b.example.com").Subject.CommonName)
+
+	// first certificate is default
+	require.Equal("a.example.com", handshake(t, store, "c.example.com").Subject.CommonName)
+}
+
+func TestStoreReloadsModifiedCertificates(t *testing.T) {
+	require := require.New(t)
+
+	directory := t.TempDir()
+	pair := writeTestPair(t, directory, "a.example.com", 1)
+
+	store, err := NewStore([]Pair{pair}, 0)
+	require.NoError(err)
+	require.Equal(int64(1), handshake(t, store, "a.example.com").SerialNumber.Int64())
+
+	// partially written certificate is skipped
+	require.NoError(ioutil.WriteFile(pair.CertFile, []byte("-----BEGIN CERTIFICATE-----"), 0600))
+	store.Reload()
+	require.Equal(int64(1), handshake(t, store, "a.example.com").SerialNumber.Int64())
+
+	// renewed certificate is loaded
+	writeTestPair(t, directory, "a.example.com", 2)
+	renewTime := time.Now().Add(time.Minute)
+	require.NoError(os.Chtimes(pair.CertFile, renewTime, renewTime))
+	store.Reload()
+	require.Equal(int64(2), handshake(t, store, "a.example.com").SerialNumber.Int64())
+}
+
+func TestNewStoreWithMissingFiles(t *testing.T) {
+	_, err := NewStore([]Pair{{CertFile: "missing.pem", KeyFile: "missing.key"}}, 0)
+	require.Error(t, err)
+
+	_, err = NewStore(nil, 0)
+	require.Error(t, err)
+}
+
+func TestParseClientAuth(t *testing.T) {
+	require := require.New(t)
+
+	for mode, expectedClientAuth := range map[string]tls.ClientAuthType{
+		"request":         tls.RequestClientCert,
+		"require":         tls.RequireAndVerifyClientCert,
+		"verify-if-given": tls.VerifyClientCertIfGiven,
+	} {
+		clientAuth, err := ParseClientAuth(mode)
+		require.NoError(err)
+		require.Equal(expectedClientAuth, clientAuth)
+	}
+
+	_, err := ParseClientAuth("none")
+	require.Error(err)
+}
+
+func TestLoadCertPool(t *testing.T) {
+	require := require.New(t)
+
+	directory := t.TempDir()
+	pair := writeTestPair(t, directory, "ca.example.com", 1)
+
+	pool, err := LoadCertPool(pair.CertFile)
+	require.NoError(err)
+	require.NotNil(pool)
+
+	_, err = LoadCertPool(pair.KeyFile)
+	require.Error(err)
+
+	_, err = LoadCertPool(filepath.Join(directory, "missing.pem"))
+	require.Error(err)
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every option of the proxy. Keys of YAML file are the same as names of command line flags
+type Config struct {
+	// HTTP connection params
+	HTTPHost          string   `yaml:"http-host"`
+	HTTPPort          int      `yaml:"http-port"`
+	HTTPReadTimeout   int      `yaml:"http-timeout-read"`
+	HTTPWriteTimeout  int      `yaml:"http-timeout-write"`
+	HTTPIdleTimeout   int      `yaml:"http-timeout-idle"`
+	TLSCert           string   `yaml:"tls-cert"`
+	TLSKey            string   `yaml:"tls-key"`
+	TLSPairs          []string `yaml:"tls-pair"`
+	TLSReloadInterval int      `yaml:"tls-reload-interval"`
+	TLSClientCA       string   `yaml:"tls-client-ca"`
+	TLSClientAuth     string   `yaml:"tls-client-auth"`
+
+	// StatsD connection params
+	StatsdHost                string   `yaml:"statsd-host"`
+	StatsdPort                int      `yaml:"statsd-port"`
+	StatsdBackends            []string `yaml:"statsd-backend"`
+	StatsdBackendMode         string   `yaml:"statsd-backend-mode"`
+	StatsdHealthCheckInterval int      `yaml:"statsd-health-check-interval"`
+	StatsdFlushInterval       int      `yaml:"statsd-flush-interval"`
+	StatsdMaxPacketSize       int      `yaml:"statsd-max-packet-size"`
+	TagFormat                 string   `yaml:"tag-format"`
+
+	// Aggregation params
+	AggregateInterval         int    `yaml:"aggregate-interval"`
+	AggregateSink             string `yaml:"aggregate-sink"`
+	AggregatePercentiles      string `yaml:"aggregate-percentiles"`
+	AggregateMaxSeries        int    `yaml:"aggregate-max-series"`
+	AggregateDeleteIdleGauges bool   `yaml:"aggregate-delete-idle-gauges"`
+
+	// Prometheus params
+	PrometheusEnabled   bool   `yaml:"prometheus"`
+	PrometheusBuckets   string `yaml:"prometheus-buckets"`
+	PrometheusMaxSeries int    `yaml:"prometheus-max-series"`
+
+	// Admin server params
+	AdminHost              string `yaml:"admin-host"`
+	AdminPort              int    `yaml:"admin-port"`
+	InternalMetricPrefix   string `yaml:"internal-metric-prefix"`
+	InternalMetricInterval int    `yaml:"internal-metric-interval"`
+
+	// Metric params
+	MetricPrefix string `yaml:"metric-prefix"`
+	KeyMode      string `yaml:"key-mode"`
+	KeyMaxLength int    `yaml:"key-max-length"`
+
+	// Authentication params
+	TokenSecret              string   `yaml:"jwt-secret"`
+	TokenPublicKeys          []string `yaml:"jwt-public-key"`
+	TokenJWKSFile            string   `yaml:"jwt-jwks-file"`
+	TokenJWKSRefreshInterval int      `yaml:"jwt-jwks-refresh-interval"`
+	TokenClockSkew           int      `yaml:"jwt-clock-skew"`
+	TokenRequireExpiry       bool     `yaml:"jwt-require-exp"`
+	TokenIssuers             []string `yaml:"jwt-issuer"`
+	TokenAudience            string   `yaml:"jwt-audience"`
+	CORSAllowedOrigins       []string `yaml:"cors-allowed-origin"`
+
+	// Debug params
+	Verbose          bool `yaml:"verbose"`
+	ProfilerHTTPPort int  `yaml:"profiler-http-port"`
+
+	// Command line only params
+	ConfigFile string `yaml:"-"`
+	Version    bool   `yaml:"-"`
+}
+
+// DefaultConfig returns config with default values of options
+func DefaultConfig() Config {
+	return Config{
+		HTTPHost:                  "127.0.0.1",
+		HTTPPort:                  8825,
+		HTTPReadTimeout:           1,
+		HTTPWriteTimeout:          1,
+		HTTPIdleTimeout:           1,
+		TLSReloadInterval:         10,
+		TLSClientAuth:             "require",
+		StatsdHost:                "127.0.0.1",
+		StatsdPort:                8125,
+		StatsdBackendMode:         BackendModeMirror,
+		StatsdHealthCheckInterval: 10,
+		StatsdFlushInterval:       0,
+		StatsdMaxPacketSize:       1432,
+		TagFormat:                 "influx",
+		AggregateInterval:         0,
+		AggregateSink:             "statsd",
+		AggregatePercentiles:      "90",
+		AggregateMaxSeries:        10000,
+		PrometheusBuckets:         ".005,.01,.025,.05,.1,.25,.5,1,2.5,5,10",
+		PrometheusMaxSeries:       10000,
+		AdminHost:                 "127.0.0.1",
+		AdminPort:                 0,
+		InternalMetricInterval:    10,
+		KeyMode:                   "strict",
+		KeyMaxLength:              0,
+		TokenJWKSRefreshInterval:  60,
+		TokenClockSkew:            30,
+	}
+}
+
+// ParseConfig reads config from YAML file, passed by "--config" flag, and overrides its values by other flags
+func ParseConfig(arguments []string) (Config, error) {
+	config := DefaultConfig()
+	if err := config.parseFlags(arguments); err != nil {
+		return Config{}, err
+	}
+
+	if config.ConfigFile == "" {
+		return config, nil
+	}
+
+	fileConfig, err := LoadConfigFile(config.ConfigFile)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := fileConfig.parseFlags(arguments); err != nil {
+		return Config{}, err
+	}
+
+	return fileConfig, nil
+}
+
+// LoadConfigFile reads config from YAML file. Options, missing in file, have default values.
+// Unknown keys are reported as error
+func LoadConfigFile(configFile string) (Config, error) {
+	config := DefaultConfig()
+
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return Config{}, fmt.Errorf("Cannot read config file: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		return Config{}, fmt.Errorf("Invalid config file %s: %v", configFile, err)
+	}
+
+	return config, nil
+}
+
+// restartOptions returns config without options, applied on reload.
+// Other options are applied on restart only
+func (config Config) restartOptions() Config {
+	config.applyReloadOptions(Config{})
+
+	return config
+}
+
+// applyReloadOptions replaces options, applied on reload, by values of reloaded config.
+// Options, applied on restart only, keep running values
+func (config *Config) applyReloadOptions(reloaded Config) {
+	config.StatsdHost = reloaded.StatsdHost
+	config.StatsdPort = reloaded.StatsdPort
+	config.StatsdBackends = reloaded.StatsdBackends
+	config.StatsdBackendMode = reloaded.StatsdBackendMode
+	config.StatsdHealthCheckInterval = reloaded.StatsdHealthCheckInterval
+	config.StatsdFlushInterval = reloaded.StatsdFlushInterval
+	config.StatsdMaxPacketSize = reloaded.StatsdMaxPacketSize
+	config.TagFormat = reloaded.TagFormat
+	config.AggregateInterval = reloaded.AggregateInterval
+	config.AggregateSink = reloaded.AggregateSink
+	config.AggregatePercentiles = reloaded.AggregatePercentiles
+	config.AggregateMaxSeries = reloaded.AggregateMaxSeries
+	config.AggregateDeleteIdleGauges = reloaded.AggregateDeleteIdleGauges
+	config.MetricPrefix = reloaded.MetricPrefix
+	config.KeyMode = reloaded.KeyMode
+	config.KeyMaxLength = reloaded.KeyMaxLength
+	config.TokenSecret = reloaded.TokenSecret
+	config.TokenPublicKeys = reloaded.TokenPublicKeys
+	config.TokenJWKSFile = reloaded.TokenJWKSFile
+	config.TokenJWKSRefreshInterval = reloaded.TokenJWKSRefreshInterval
+	config.TokenClockSkew = reloaded.TokenClockSkew
+	config.TokenRequireExpiry = reloaded.TokenRequireExpiry
+	config.TokenIssuers = reloaded.TokenIssuers
+	config.TokenAudience = reloaded.TokenAudience
+	config.CORSAllowedOrigins = reloaded.CORSAllowedOrigins
+}
+
+// parseFlags overrides values of config by command line flags
+func (config *Config) parseFlags(arguments []string) error {
+	flagSet := flag.NewFlagSet("statsd-http-proxy", flag.ContinueOnError)
+
+	flagSet.StringVar(&config.ConfigFile, "config", config.ConfigFile, "Path to YAML config file. Flags override values of file")
+	flagSet.StringVar(&config.HTTPHost, "http-host", config.HTTPHost, "HTTP Host")
+	flagSet.IntVar(&config.HTTPPort, "http-port", config.HTTPPort, "HTTP Port")
+	flagSet.IntVar(&config.HTTPReadTimeout, "http-timeout-read", config.HTTPReadTimeout, "The maximum duration in seconds for reading the entire request, including the body")
+	flagSet.IntVar(&config.HTTPWriteTimeout, "http-timeout-write", config.HTTPWriteTimeout, "The maximum duration in seconds before timing out writes of the respons")
+	flagSet.IntVar(&config.HTTPIdleTimeout, "http-timeout-idle", config.HTTPIdleTimeout, "The maximum amount of time in seconds to wait for the next request when keep-alives are enabled")
+	flagSet.StringVar(&config.TLSCert, "tls-cert", config.TLSCert, "TLS certificate to enable HTTPS")
+	flagSet.StringVar(&config.TLSKey, "tls-key", config.TLSKey, "TLS private key  to enable HTTPS")
+	flagSet.Var(&stringsFlag{values: &config.TLSPairs}, "tls-pair", "Pair of TLS certificate and private key \"cert.pem,key.pem\", may be passed several times to select certificate by SNI")
+	flagSet.IntVar(&config.TLSReloadInterval, "tls-reload-interval", config.TLSReloadInterval, "Interval in seconds to check TLS certificates for renewal, 0 to disable")
+	flagSet.StringVar(&config.TLSClientCA, "tls-client-ca", config.TLSClientCA, "Bundle of CA certificates to verify TLS client certificates")
+	flagSet.StringVar(&config.TLSClientAuth, "tls-client-auth", config.TLSClientAuth, "Authentication of TLS clients by tls-client-ca: request, require or verify-if-given")
+	flagSet.StringVar(&config.StatsdHost, "statsd-host", config.StatsdHost, "StatsD Host")
+	flagSet.IntVar(&config.StatsdPort, "statsd-port", config.StatsdPort, "StatsD Port")
+	flagSet.Var(&stringsFlag{values: &config.StatsdBackends}, "statsd-backend", "URL of StatsD backend \"udp://host:port?tag-format=format\", may be passed several times to mirror metrics to every backend. Replaces statsd-host and statsd-port")
+	flagSet.StringVar(&config.StatsdBackendMode, "statsd-backend-mode", config.StatsdBackendMode, "Distribution of metrics between StatsD backends: mirror sends every metric to all backends, hash routes every key to one backend by consistent hash ring, none does not send metrics to StatsD")
+	flagSet.IntVar(&config.StatsdHealthCheckInterval, "statsd-health-check-interval", config.StatsdHealthCheckInterval, "Interval in seconds to check health of StatsD backends with health-port in hash mode, 0 to disable")
+	flagSet.IntVar(&config.StatsdFlushInterval, "statsd-flush-interval", config.StatsdFlushInterval, "Interval in milliseconds to send buffered metrics to StatsD, 0 to send every metric immediately")
+	flagSet.IntVar(&config.StatsdMaxPacketSize, "statsd-max-packet-size", config.StatsdMaxPacketSize, "Maximum size in bytes of packet with buffered metrics")
+	flagSet.IntVar(&config.AggregateInterval, "aggregate-interval", config.AggregateInterval, "Interval in seconds to aggregate metrics in process and flush aggregates to sink, 0 to send every metric to StatsD")
+	flagSet.StringVar(&config.AggregateSink, "aggregate-sink", config.AggregateSink, "Sink of aggregated metrics: statsd to send them to StatsD backends, or graphite://host:port")
+	flagSet.StringVar(&config.AggregatePercentiles, "aggregate-percentiles", config.AggregatePercentiles, "Comma-separated percentiles of aggregated timers")
+	flagSet.IntVar(&config.AggregateMaxSeries, "aggregate-max-series", config.AggregateMaxSeries, "Maximum number of aggregated series, metrics of new series are dropped over it. 0 for unlimited")
+	flagSet.BoolVar(&config.AggregateDeleteIdleGauges, "aggregate-delete-idle-gauges", config.AggregateDeleteIdleGauges, "Do not flush gauges, not updated over aggregate interval")
+	flagSet.BoolVar(&config.PrometheusEnabled, "prometheus", config.PrometheusEnabled, "Expose metrics on /metrics in Prometheus text format")
+	flagSet.StringVar(&config.PrometheusBuckets, "prometheus-buckets", config.PrometheusBuckets, "Comma-separated upper bounds of Prometheus histogram buckets, in seconds for timings")
+	flagSet.IntVar(&config.PrometheusMaxSeries, "prometheus-max-series", config.PrometheusMaxSeries, "Maximum number of Prometheus series, metrics of new series are dropped over it. 0 for unlimited")
+	flagSet.StringVar(&config.AdminHost, "admin-host", config.AdminHost, "Host of admin server with self metrics")
+	flagSet.IntVar(&config.AdminPort, "admin-port", config.AdminPort, "Port of admin server with self metrics on /metrics, 0 to disable")
+	flagSet.StringVar(&config.InternalMetricPrefix, "internal-metric-prefix", config.InternalMetricPrefix, "Prefix of self metrics, sent to StatsD. If not set, self metrics are not sent")
+	flagSet.IntVar(&config.InternalMetricInterval, "internal-metric-interval", config.InternalMetricInterval, "Interval in seconds to send self metrics to StatsD")
+	flagSet.StringVar(&config.TagFormat, "tag-format", config.TagFormat, "Format of tags sent to StatsD: influx, dogstatsd, signalfx, graphite or none")
+	flagSet.StringVar(&config.MetricPrefix, "metric-prefix", config.MetricPrefix, "Prefix of metric names of lines sent to /raw")
+	flagSet.StringVar(&config.KeyMode, "key-mode", config.KeyMode, "Validation of metric keys: strict rejects keys with forbidden characters, lenient replaces them with '_'")
+	flagSet.IntVar(&config.KeyMaxLength, "key-max-length", config.KeyMaxLength, "Maximum length of metric key, 0 for unlimited")
+	flagSet.StringVar(&config.TokenSecret, "jwt-secret", config.TokenSecret, "Secret to encrypt JWT")
+	flagSet.Var(&stringsFlag{values: &config.TokenPublicKeys}, "jwt-public-key", "PEM file with public key to verify JWT signed by RS256, ES256 or EdDSA, may be passed several times")
+	flagSet.StringVar(&config.TokenJWKSFile, "jwt-jwks-file", config.TokenJWKSFile, "JWKS file with public keys to verify JWT, selected by kid of token")
+	flagSet.IntVar(&config.TokenJWKSRefreshInterval, "jwt-jwks-refresh-interval", config.TokenJWKSRefreshInterval, "Interval in seconds to load JWKS file again, 0 to disable")
+	flagSet.IntVar(&config.TokenClockSkew, "jwt-clock-skew", config.TokenClockSkew, "Allowed clock skew in seconds to validate exp, nbf and iat claims of JWT")
+	flagSet.BoolVar(&config.TokenRequireExpiry, "jwt-require-exp", config.TokenRequireExpiry, "Reject JWT without exp claim")
+	flagSet.Var(&stringsFlag{values: &config.TokenIssuers}, "jwt-issuer", "Issuer allowed in iss claim of JWT, may be passed several times. If not set, any issuer is allowed")
+	flagSet.StringVar(&config.TokenAudience, "jwt-audience", config.TokenAudience, "Audience required in aud claim of JWT")
+	flagSet.Var(&stringsFlag{values: &config.CORSAllowedOrigins}, "cors-allowed-origin", "Origin allowed to send requests, may be passed several times. If not set, any origin is allowed")
+	flagSet.BoolVar(&config.Verbose, "verbose", config.Verbose, "Verbose")
+	flagSet.BoolVar(&config.Version, "version", config.Version, "Show version")
+	flagSet.IntVar(&config.ProfilerHTTPPort, "profiler-http-port", config.ProfilerHTTPPort, "Start profiler localhost")
+
+	return flagSet.Parse(arguments)
+}
+
+// stringsFlag collects values of the flag, passed several times.
+// Values from config file are replaced by the first passed flag
+type stringsFlag struct {
+	values *[]string
+	set    bool
+}
+
+func (flag *stringsFlag) String() string {
+	if flag.values == nil {
+		return ""
+	}
+
+	return strings.Join(*flag.values, ",")
+}
+
+func (flag *stringsFlag) Set(value string) error {
+	if !flag.set {
+		*flag.values = nil
+		flag.set = true
+	}
+
+	*flag.values = append(*flag.values, value)
+	return nil
+}
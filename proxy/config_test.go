@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(content), 0600))
+
+	return configFile
+}
+
+func TestParseConfigWithFlagsOnly(t *testing.T) {
+	require := require.New(t)
+
+	config, err := ParseConfig([]string{"--http-port=8080", "--statsd-backend=udp://a:8125", "--statsd-backend=udp://b:8125"})
+	require.NoError(err)
+
+	expectedConfig := DefaultConfig()
+	expectedConfig.HTTPPort = 8080
+	expectedConfig.StatsdBackends = []string{"udp://a:8125", "udp://b:8125"}
+	require.Equal(expectedConfig, config)
+}
+
+func TestParseConfigWithFileAndFlags(t *testing.T) {
+	require := require.New(t)
+
+	configFile := writeConfigFile(t, `
+http-port: 8080
+metric-prefix: frontend
+jwt-secret: somesecret
+statsd-backend:
+  - udp://a:8125
+  - udp://b:8125
+`)
+
+	config, err := ParseConfig([]string{"--config=" + configFile, "--jwt-secret=othersecret"})
+	require.NoError(err)
+	require.Equal(8080, config.HTTPPort)
+	require.Equal("frontend", config.MetricPrefix)
+	require.Equal("othersecret", config.TokenSecret)
+	require.Equal([]string{"udp://a:8125", "udp://b:8125"}, config.StatsdBackends)
+	require.Equal(DefaultConfig().StatsdPort, config.StatsdPort)
+
+	// backends of file are replaced by flags
+	config, err = ParseConfig([]string{"--config=" + configFile, "--statsd-backend=udp://c:8125"})
+	require.NoError(err)
+	require.Equal([]string{"udp://c:8125"}, config.StatsdBackends)
+}
+
+func TestParseConfigWithEmptyFile(t *testing.T) {
+	require := require.New(t)
+
+	configFile := writeConfigFile(t, "")
+
+	config, err := ParseConfig([]string{"--config", configFile})
+	require.NoError(err)
+
+	expectedConfig := DefaultConfig()
+	expectedConfig.ConfigFile = configFile
+	require.Equal(expectedConfig, config)
+}
+
+func TestParseConfigReportsUnknownKeys(t *testing.T) {
+	require := require.New(t)
+
+	configFile := writeConfigFile(t, "http-port: 8080\nhttp-prot: 8081\njwt-secert: secret\n")
+
+	_, err := ParseConfig([]string{"--config", configFile})
+	require.Error(err)
+	require.Contains(err.Error(), "field http-prot not found")
+	require.Contains(err.Error(), "field jwt-secert not found")
+
+	_, err = ParseConfig([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")})
+	require.Error(err)
+}
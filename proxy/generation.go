@@ -0,0 +1,251 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/johnseekins/statsd-http-proxy/proxy/jwtkeys"
+	"github.com/johnseekins/statsd-http-proxy/proxy/middleware"
+	"github.com/johnseekins/statsd-http-proxy/proxy/routehandler"
+	"github.com/johnseekins/statsd-http-proxy/proxy/router"
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
+)
+
+// generation is a set of components, built from reloadable options of config.
+// On reload new generation replaces current one, which is closed when its in-flight requests are handled
+type generation struct {
+	handler      http.Handler
+	statsdClient statsdclient.StatsdClientInterface
+	// backendClient sends metrics to StatsD backends, and is a part of statsdClient
+	backendClient statsdclient.StatsdClientInterface
+	// tokenKeys verify JWT, nil if JWT is not validated
+	tokenKeys *jwtkeys.KeySet
+	inFlight  sync.WaitGroup
+}
+
+// newGeneration builds StatsD client and HTTP handler from config
+func (proxyServer *Server) newGeneration(config Config) (*generation, error) {
+	// prepare metric prefix
+	metricPrefix := config.MetricPrefix
+	if metricPrefix != "" && (metricPrefix)[len(metricPrefix)-1:] != "_" {
+		metricPrefix = metricPrefix + "_"
+	}
+
+	// encode tags in format of StatsD server
+	tagFormat, err := statsdclient.ParseTagFormat(config.TagFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect StatsD backends, replacing default one if any configured
+	backends := []statsdclient.Backend{{Network: statsdclient.TransportUDP, Host: config.StatsdHost, Port: config.StatsdPort, TagFormat: tagFormat}}
+	if len(config.StatsdBackends) > 0 {
+		backends = make([]statsdclient.Backend, 0, len(config.StatsdBackends))
+		for _, backendURL := range config.StatsdBackends {
+			backend, err := statsdclient.ParseBackend(backendURL, tagFormat)
+			if err != nil {
+				return nil, err
+			}
+			backends = append(backends, backend)
+		}
+	}
+
+	newStatsdClient := func(backend statsdclient.Backend) statsdclient.StatsdClientInterface {
+		client := statsdclient.NewTransportClient(
+			backend.Network,
+			backend.Address(),
+			backend.TagFormat,
+			time.Duration(config.StatsdFlushInterval)*time.Millisecond,
+			config.StatsdMaxPacketSize,
+		)
+
+		sendErrorTags := []statsdclient.Tag{{Key: "backend", Value: backend.Address()}}
+		client.SetSendErrorHandler(func(err error) {
+			proxyServer.selfMetrics.Count("statsd.send_errors", 1, 1, sendErrorTags)
+		})
+
+		return client
+	}
+
+	// create StatsD Client
+	var statsdClient statsdclient.StatsdClientInterface
+	switch config.StatsdBackendMode {
+	case BackendModeMirror:
+		backendClients := make([]statsdclient.StatsdClientInterface, 0, len(backends))
+		for _, backend := range backends {
+			backendClients = append(backendClients, newStatsdClient(backend))
+		}
+
+		statsdClient = backendClients[0]
+		if len(backendClients) > 1 {
+			statsdClient = statsdclient.NewMultiClient(backendClients...)
+		}
+	case BackendModeHash:
+		nodes := make([]statsdclient.HashRingNode, 0, len(backends))
+		for _, backend := range backends {
+			node := statsdclient.HashRingNode{
+				Name:   backend.Address(),
+				Client: newStatsdClient(backend),
+			}
+			if backend.HealthPort > 0 {
+				node.HealthCheck = statsdclient.NewAdminHealthCheck(backend.Host, backend.HealthPort, healthCheckTimeout)
+			}
+			nodes = append(nodes, node)
+		}
+
+		statsdClient = statsdclient.NewHashRingClient(nodes, time.Duration(config.StatsdHealthCheckInterval)*time.Second)
+	case BackendModeNone:
+		statsdClient = statsdclient.NopClient{}
+	default:
+		return nil, fmt.Errorf("Invalid StatsD backend mode %q", config.StatsdBackendMode)
+	}
+
+	backendClient := statsdClient
+
+	// aggregate metrics in process instead of StatsD server
+	if config.AggregateInterval > 0 {
+		aggregateSink, err := statsdclient.ParseAggregateSink(config.AggregateSink, statsdClient)
+		if err != nil {
+			return nil, err
+		}
+
+		percentiles, err := parseFloatList(config.AggregatePercentiles)
+		if err == nil {
+			err = validatePercentiles(percentiles)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		aggregator := statsdclient.NewAggregator(
+			statsdClient,
+			aggregateSink,
+			time.Duration(config.AggregateInterval)*time.Second,
+			percentiles,
+			config.AggregateMaxSeries,
+			config.AggregateDeleteIdleGauges,
+		)
+		aggregator.SetDroppedSeriesHandler(func(key string) {
+			proxyServer.selfMetrics.Count("aggregator.dropped_series", 1, 1, nil)
+		})
+		statsdClient = aggregator
+	}
+
+	// expose metrics to Prometheus
+	var metricsHandler http.Handler
+	if proxyServer.prometheusClient != nil {
+		metricsHandler = proxyServer.prometheusClient
+
+		if config.StatsdBackendMode == BackendModeNone && config.AggregateInterval <= 0 {
+			statsdClient = proxyServer.prometheusClient
+		} else {
+			statsdClient = statsdclient.NewMultiClient(statsdClient, proxyServer.prometheusClient)
+		}
+	}
+
+	// sample metrics once for all backends
+	statsdClient = statsdclient.NewSamplingClient(statsdClient)
+
+	// validate metric keys
+	if err := routehandler.ValidateKeyMode(config.KeyMode); err != nil {
+		return nil, err
+	}
+
+	// build route handler
+	routeHandler := routehandler.NewRouteHandler(
+		statsdClient,
+		metricPrefix,
+		routehandler.KeyRules{
+			Mode:      config.KeyMode,
+			MaxLength: config.KeyMaxLength,
+		},
+	)
+
+	// load keys to verify JWT
+	var tokenKeys *jwtkeys.KeySet
+	if config.TokenSecret != "" || len(config.TokenPublicKeys) > 0 || config.TokenJWKSFile != "" {
+		tokenKeys, err = jwtkeys.NewKeySet(
+			config.TokenSecret,
+			config.TokenPublicKeys,
+			config.TokenJWKSFile,
+			time.Duration(config.TokenJWKSRefreshInterval)*time.Second,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// build router
+	handler := router.NewHTTPRouter(
+		routeHandler,
+		tokenKeys,
+		middleware.ClaimRules{
+			ClockSkew:     time.Duration(config.TokenClockSkew) * time.Second,
+			RequireExpiry: config.TokenRequireExpiry,
+			Issuers:       config.TokenIssuers,
+			Audience:      config.TokenAudience,
+		},
+		config.CORSAllowedOrigins,
+		metricsHandler,
+		proxyServer.selfMetrics,
+	)
+
+	return &generation{
+		handler:       handler,
+		statsdClient:  statsdClient,
+		backendClient: backendClient,
+		tokenKeys:     tokenKeys,
+	}, nil
+}
+
+// open connects to StatsD and starts refreshing of JWT keys
+func (currentGeneration *generation) open() {
+	currentGeneration.statsdClient.Open()
+
+	if currentGeneration.tokenKeys != nil {
+		currentGeneration.tokenKeys.Watch()
+	}
+}
+
+// close sends buffered metrics, closes StatsD connection and stops refreshing of JWT keys
+func (currentGeneration *generation) close() {
+	if currentGeneration.tokenKeys != nil {
+		currentGeneration.tokenKeys.Close()
+	}
+
+	currentGeneration.statsdClient.Close()
+}
+
+// acquireGeneration returns current generation, which must be released by inFlight.Done()
+func (proxyServer *Server) acquireGeneration() *generation {
+	proxyServer.generationLock.RLock()
+	defer proxyServer.generationLock.RUnlock()
+
+	currentGeneration := proxyServer.generation
+	currentGeneration.inFlight.Add(1)
+
+	return currentGeneration
+}
+
+// ServeHTTP handles request by current generation
+func (proxyServer *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	currentGeneration := proxyServer.acquireGeneration()
+	defer currentGeneration.inFlight.Done()
+
+	currentGeneration.handler.ServeHTTP(w, r)
+}
+
+// backendSink sends aggregated self metrics to StatsD backends of current generation
+type backendSink struct {
+	proxyServer *Server
+	prefix      string
+}
+
+func (sink *backendSink) Flush(aggregates statsdclient.Aggregates) {
+	currentGeneration := sink.proxyServer.acquireGeneration()
+	defer currentGeneration.inFlight.Done()
+
+	statsdclient.NewStatsdSink(statsdclient.NewPrefixClient(sink.prefix, currentGeneration.backendClient)).Flush(aggregates)
+}
@@ -0,0 +1,53 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SigningMethodEdDSA signs and verifies JWT by Ed25519 keys, which are not supported by jwt-go
+type SigningMethodEdDSA struct{}
+
+// SigningMethodEd25519 is a "EdDSA" signing method of JWT
+var SigningMethodEd25519 = &SigningMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEd25519.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEd25519
+	})
+}
+
+// Alg returns name of algorithm in JWT header
+func (method *SigningMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+// Verify checks signature by ed25519.PublicKey
+func (method *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(publicKey) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+
+	signatureBytes, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), signatureBytes) {
+		return jwt.ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+// Sign signs string by ed25519.PrivateKey
+func (method *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
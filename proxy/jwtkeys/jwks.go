@@ -0,0 +1,155 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// namedKey is a public key of JWKS with its ID and algorithm, which are optional
+type namedKey struct {
+	id        string
+	algorithm string
+	key       interface{}
+}
+
+// jsonWebKey is a key of JWKS document, as described in RFC 7517
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+}
+
+// parseJWKS parses public signing keys of JWKS document. Encryption keys and unknown key types are skipped
+func parseJWKS(document []byte) ([]namedKey, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.Unmarshal(document, &jwks); err != nil {
+		return nil, fmt.Errorf("Invalid JWKS document: %v", err)
+	}
+
+	keys := make([]namedKey, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key interface{}
+		var err error
+		switch jwk.KeyType {
+		case "RSA":
+			key, err = jwk.rsaPublicKey()
+		case "EC":
+			key, err = jwk.ecdsaPublicKey()
+		case "OKP":
+			key, err = jwk.ed25519PublicKey()
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid JWKS key %q: %v", jwk.KeyID, err)
+		}
+
+		keys = append(keys, namedKey{id: jwk.KeyID, algorithm: jwk.Algorithm, key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("No signing keys in JWKS document")
+	}
+
+	return keys, nil
+}
+
+func (jwk jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := decodeBigInt(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk jsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Curve {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+	}
+
+	x, err := decodeBigInt(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := decodeBigInt(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func (jwk jsonWebKey) ed25519PublicKey() (ed25519.PublicKey, error) {
+	if jwk.Curve != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 key size")
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+// decodeBigInt decodes unsigned big-endian integer, encoded by base64url without padding
+func decodeBigInt(encoded string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decoded) == 0 {
+		return nil, errors.New("empty integer")
+	}
+
+	return new(big.Int).SetBytes(decoded), nil
+}
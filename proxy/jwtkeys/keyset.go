@@ -0,0 +1,253 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// KeySet keeps keys to verify signatures of JWT: HMAC secret, PEM encoded public keys and keys of JWKS file.
+// Keys of JWKS file are selected by "kid" of token, other keys are tried in turn
+type KeySet struct {
+	// keys without ID: HMAC secret and PEM encoded public keys
+	keys []namedKey
+
+	// keys of JWKS file, loaded again every refreshInterval
+	jwksFile        string
+	jwksLock        sync.RWMutex
+	jwksKeys        []namedKey
+	refreshInterval time.Duration
+	refreshStop     chan struct{}
+	refreshDone     sync.WaitGroup
+}
+
+// NewSecretKeySet creates key set to verify tokens, signed by HMAC secret
+func NewSecretKeySet(secret string) *KeySet {
+	return &KeySet{keys: []namedKey{{key: []byte(secret)}}}
+}
+
+// NewKeySet creates key set from HMAC secret, PEM encoded public keys and JWKS file, any of which may be empty.
+// JWKS file is loaded again every refreshInterval
+func NewKeySet(secret string, publicKeyFiles []string, jwksFile string, refreshInterval time.Duration) (*KeySet, error) {
+	keySet := &KeySet{
+		jwksFile:        jwksFile,
+		refreshInterval: refreshInterval,
+	}
+
+	if secret != "" {
+		keySet.keys = append(keySet.keys, namedKey{key: []byte(secret)})
+	}
+
+	for _, publicKeyFile := range publicKeyFiles {
+		publicKeys, err := loadPublicKeys(publicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, publicKey := range publicKeys {
+			keySet.keys = append(keySet.keys, namedKey{key: publicKey})
+		}
+	}
+
+	if jwksFile != "" {
+		if err := keySet.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	return keySet, nil
+}
+
+// loadPublicKeys loads public keys and certificates from PEM file
+func loadPublicKeys(publicKeyFile string) ([]interface{}, error) {
+	encodedKeys, err := ioutil.ReadFile(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKeys []interface{}
+	for {
+		var block *pem.Block
+		block, encodedKeys = pem.Decode(encodedKeys)
+		if block == nil {
+			break
+		}
+
+		var publicKey interface{}
+		switch block.Type {
+		case "PUBLIC KEY":
+			publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var certificate *x509.Certificate
+			if certificate, err = x509.ParseCertificate(block.Bytes); err == nil {
+				publicKey = certificate.PublicKey
+			}
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid public key in %s: %v", publicKeyFile, err)
+		}
+
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	if len(publicKeys) == 0 {
+		return nil, fmt.Errorf("No public keys in %s", publicKeyFile)
+	}
+
+	return publicKeys, nil
+}
+
+// Refresh loads keys of JWKS file again. If file is invalid, previous keys are kept
+func (keySet *KeySet) Refresh() error {
+	document, err := ioutil.ReadFile(keySet.jwksFile)
+	if err != nil {
+		return err
+	}
+
+	jwksKeys, err := parseJWKS(document)
+	if err != nil {
+		return fmt.Errorf("%v in %s", err, keySet.jwksFile)
+	}
+
+	keySet.jwksLock.Lock()
+	keySet.jwksKeys = jwksKeys
+	keySet.jwksLock.Unlock()
+
+	return nil
+}
+
+// Watch starts loading of JWKS file every refresh interval
+func (keySet *KeySet) Watch() {
+	if keySet.jwksFile == "" || keySet.refreshInterval <= 0 {
+		return
+	}
+
+	keySet.refreshStop = make(chan struct{})
+	keySet.refreshDone.Add(1)
+
+	go func(ticker *time.Ticker, stop chan struct{}) {
+		defer keySet.refreshDone.Done()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := keySet.Refresh(); err != nil {
+					log.WithFields(log.Fields{"Error": err}).Error("Cannot refresh JWKS")
+				}
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}(time.NewTicker(keySet.refreshInterval), keySet.refreshStop)
+}
+
+// Close stops loading of JWKS file
+func (keySet *KeySet) Close() {
+	if keySet.refreshStop == nil {
+		return
+	}
+
+	close(keySet.refreshStop)
+	keySet.refreshDone.Wait()
+	keySet.refreshStop = nil
+}
+
+// Parse parses token and verifies its signature by keys of the set. Claims are not validated
+func (keySet *KeySet) Parse(tokenString string) (*jwt.Token, error) {
+	unverifiedToken, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := keySet.keysFor(unverifiedToken)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No key to verify token signed by %v", unverifiedToken.Header["alg"])
+	}
+
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+
+	var token *jwt.Token
+	for _, key := range keys {
+		token, err = parser.Parse(tokenString, func(*jwt.Token) (interface{}, error) {
+			return key, nil
+		})
+
+		// try next key only if signature does not match
+		if validationError, ok := err.(*jwt.ValidationError); !ok || validationError.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			break
+		}
+	}
+
+	return token, err
+}
+
+// keysFor returns keys, suitable to verify signature of token.
+// Key of JWKS with "kid" of the token is used if found, otherwise keys without ID are returned
+func (keySet *KeySet) keysFor(token *jwt.Token) []interface{} {
+	keySet.jwksLock.RLock()
+	defer keySet.jwksLock.RUnlock()
+
+	if keyID, _ := token.Header["kid"].(string); keyID != "" {
+		for _, jwksKey := range keySet.jwksKeys {
+			if jwksKey.id == keyID && jwksKey.matches(token.Method) {
+				return []interface{}{jwksKey.key}
+			}
+		}
+	}
+
+	var keys []interface{}
+	for _, key := range keySet.keys {
+		if key.matches(token.Method) {
+			keys = append(keys, key.key)
+		}
+	}
+
+	for _, jwksKey := range keySet.jwksKeys {
+		if jwksKey.id == "" && jwksKey.matches(token.Method) {
+			keys = append(keys, jwksKey.key)
+		}
+	}
+
+	return keys
+}
+
+// matches checks that key may verify signature of method, so tokens can not pick algorithm of other key type
+func (key namedKey) matches(method jwt.SigningMethod) bool {
+	if key.algorithm != "" && key.algorithm != method.Alg() {
+		return false
+	}
+
+	switch key.key.(type) {
+	case []byte:
+		_, ok := method.(*jwt.SigningMethodHMAC)
+		return ok
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	case ed25519.PublicKey:
+		_, ok := method.(*SigningMethodEdDSA)
+		return ok
+	}
+
+	return false
+}
@@ -0,0 +1,207 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+// writePublicKey writes PEM encoded public key into directory
+func writePublicKey(t *testing.T, directory string, name string, publicKey interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	publicKeyFile := filepath.Join(directory, name)
+	require.NoError(t, ioutil.WriteFile(publicKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	return publicKeyFile
+}
+
+// signToken signs token with subject by key, and adds "kid" to header if not empty
+func signToken(t *testing.T, method jwt.SigningMethod, keyID string, key interface{}) string {
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "emitter"})
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+
+	tokenString, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return tokenString
+}
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func TestKeySetWithPublicKeys(t *testing.T) {
+	require := require.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+	ed25519PublicKey, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	otherECDSAKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	directory := t.TempDir()
+	keySet, err := NewKeySet(
+		"somesecret",
+		[]string{
+			writePublicKey(t, directory, "rsa.pem", &rsaKey.PublicKey),
+			writePublicKey(t, directory, "other-ecdsa.pem", &otherECDSAKey.PublicKey),
+			writePublicKey(t, directory, "ecdsa.pem", &ecdsaKey.PublicKey),
+			writePublicKey(t, directory, "ed25519.pem", ed25519PublicKey),
+		},
+		"",
+		0,
+	)
+	require.NoError(err)
+
+	for _, tokenString := range []string{
+		signToken(t, jwt.SigningMethodHS256, "", []byte("somesecret")),
+		signToken(t, jwt.SigningMethodRS256, "", rsaKey),
+		signToken(t, jwt.SigningMethodES256, "", ecdsaKey),
+		signToken(t, jwt.SigningMethodES256, "", otherECDSAKey),
+		signToken(t, SigningMethodEd25519, "", ed25519Key),
+	} {
+		token, err := keySet.Parse(tokenString)
+		require.NoError(err)
+		require.Equal("emitter", token.Claims.(jwt.MapClaims)["sub"])
+	}
+
+	// unknown keys and algorithms are rejected
+	unknownKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	rsaPublicKeyPEM, err := ioutil.ReadFile(filepath.Join(directory, "rsa.pem"))
+	require.NoError(err)
+
+	for _, tokenString := range []string{
+		signToken(t, jwt.SigningMethodHS256, "", []byte("othersecret")),
+		signToken(t, jwt.SigningMethodES256, "", unknownKey),
+		// public key is not accepted as HMAC secret
+		signToken(t, jwt.SigningMethodHS256, "", rsaPublicKeyPEM),
+		signToken(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType),
+	} {
+		_, err := keySet.Parse(tokenString)
+		require.Error(err)
+	}
+}
+
+func TestKeySetWithJWKS(t *testing.T) {
+	require := require.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+	ed25519PublicKey, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(ioutil.WriteFile(jwksFile, []byte(fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": %q, "e": %q},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": %q},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "oct", "kid": "secret-1", "k": "c29tZXNlY3JldA"}
+	]}`,
+		encodeBigInt(rsaKey.N),
+		encodeBigInt(big.NewInt(int64(rsaKey.E))),
+		encodeBigInt(ecdsaKey.X),
+		encodeBigInt(ecdsaKey.Y),
+		base64.RawURLEncoding.EncodeToString(ed25519PublicKey),
+	)), 0600))
+
+	keySet, err := NewKeySet("", nil, jwksFile, 0)
+	require.NoError(err)
+
+	for _, tokenString := range []string{
+		signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey),
+		signToken(t, jwt.SigningMethodES256, "ec-1", ecdsaKey),
+		signToken(t, SigningMethodEd25519, "ed-1", ed25519Key),
+	} {
+		_, err := keySet.Parse(tokenString)
+		require.NoError(err)
+	}
+
+	for _, tokenString := range []string{
+		// key is selected by kid
+		signToken(t, jwt.SigningMethodES256, "rsa-1", ecdsaKey),
+		signToken(t, jwt.SigningMethodES256, "", ecdsaKey),
+		// algorithm of key is enforced
+		signToken(t, jwt.SigningMethodRS512, "rsa-1", rsaKey),
+		// symmetric keys of JWKS are not supported
+		signToken(t, jwt.SigningMethodHS256, "secret-1", []byte("somesecret")),
+	} {
+		_, err := keySet.Parse(tokenString)
+		require.Error(err)
+	}
+
+	// invalid file keeps previous keys
+	require.NoError(ioutil.WriteFile(jwksFile, []byte(`{"keys": [`), 0600))
+	require.Error(keySet.Refresh())
+
+	_, err = keySet.Parse(signToken(t, jwt.SigningMethodES256, "ec-1", ecdsaKey))
+	require.NoError(err)
+
+	// rotated keys replace previous ones
+	rotatedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	require.NoError(ioutil.WriteFile(jwksFile, []byte(fmt.Sprintf(
+		`{"keys": [{"kty": "EC", "kid": "ec-2", "crv": "P-256", "x": %q, "y": %q}]}`,
+		encodeBigInt(rotatedKey.X),
+		encodeBigInt(rotatedKey.Y),
+	)), 0600))
+	require.NoError(keySet.Refresh())
+
+	_, err = keySet.Parse(signToken(t, jwt.SigningMethodES256, "ec-2", rotatedKey))
+	require.NoError(err)
+
+	_, err = keySet.Parse(signToken(t, jwt.SigningMethodES256, "ec-1", ecdsaKey))
+	require.Error(err)
+}
+
+func TestNewKeySetWithInvalidFiles(t *testing.T) {
+	require := require.New(t)
+
+	directory := t.TempDir()
+	invalidFile := filepath.Join(directory, "invalid")
+	require.NoError(ioutil.WriteFile(invalidFile, []byte("not a key"), 0600))
+
+	_, err := NewKeySet("", []string{invalidFile}, "", 0)
+	require.Error(err)
+
+	_, err = NewKeySet("", []string{filepath.Join(directory, "missing.pem")}, "", 0)
+	require.Error(err)
+
+	_, err = NewKeySet("", nil, invalidFile, 0)
+	require.Error(err)
+
+	for _, document := range []string{
+		`{"keys": []}`,
+		`{"keys": [{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`,
+		`{"keys": [{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": "AQAB"}]}`,
+		`{"keys": [{"kty": "RSA", "kid": "rsa-1", "n": "AQAB"}]}`,
+	} {
+		_, err := parseJWKS([]byte(document))
+		require.Error(err, document)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// clientSubjectContextKey is a key of verified client certificate subject in request context
+type clientSubjectContextKey struct{}
+
+// ClientSubject returns subject of client certificate, verified by the client CA bundle
+func ClientSubject(r *http.Request) (pkix.Name, bool) {
+	subject, ok := r.Context().Value(clientSubjectContextKey{}).(pkix.Name)
+	return subject, ok
+}
+
+// ClientCertificate passes subject of verified client certificate to next handlers.
+// Certificates, requested but not verified on handshake, are verified by clientCAs
+func ClientCertificate(next http.Handler, clientCAs *x509.CertPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var leaf *x509.Certificate
+		if len(r.TLS.VerifiedChains) > 0 {
+			leaf = r.TLS.VerifiedChains[0][0]
+		} else if clientCAs != nil {
+			intermediates := x509.NewCertPool()
+			for _, certificate := range r.TLS.PeerCertificates[1:] {
+				intermediates.AddCert(certificate)
+			}
+
+			_, err := r.TLS.PeerCertificates[0].Verify(x509.VerifyOptions{
+				Roots:         clientCAs,
+				Intermediates: intermediates,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			})
+			if err != nil {
+				log.WithFields(log.Fields{"Error": err}).Debug("Client certificate not verified")
+			} else {
+				leaf = r.TLS.PeerCertificates[0]
+			}
+		}
+
+		if leaf != nil {
+			r = r.WithContext(context.WithValue(r.Context(), clientSubjectContextKey{}, leaf.Subject))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// createTestCertificate creates certificate with common name, signed by parent, or self-signed CA if parent is nil
+func createTestCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Metrics"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, privateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return certificate, privateKey
+}
+
+func TestClientCertificate(t *testing.T) {
+	require := require.New(t)
+
+	ca, caKey := createTestCertificate(t, "Test CA", nil, nil)
+	client, _ := createTestCertificate(t, "emitter-1", ca, caKey)
+	otherCA, otherCAKey := createTestCertificate(t, "Other CA", nil, nil)
+	otherClient, _ := createTestCertificate(t, "emitter-2", otherCA, otherCAKey)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	var subject pkix.Name
+	var verified bool
+	handler := ClientCertificate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, verified = ClientSubject(r)
+	}), clientCAs)
+
+	for _, testCase := range []struct {
+		name             string
+		connectionState  *tls.ConnectionState
+		expectedVerified bool
+	}{
+		{"plain HTTP", nil, false},
+		{"no certificate", &tls.ConnectionState{}, false},
+		{"verified on handshake", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}, VerifiedChains: [][]*x509.Certificate{{client, ca}}}, true},
+		{"requested certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}, true},
+		{"requested certificate of other CA", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{otherClient}}, false},
+	} {
+		request := httptest.NewRequest("POST", "https://testing/count/some.key", nil)
+		request.TLS = testCase.connectionState
+
+		subject, verified = pkix.Name{}, false
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		require.Equal(testCase.expectedVerified, verified, testCase.name)
+		if testCase.expectedVerified {
+			require.Equal("emitter-1", subject.CommonName, testCase.name)
+		}
+	}
+}
@@ -2,13 +2,34 @@ package middleware
 
 import "net/http"
 
+// IsOriginAllowed checks if origin is in the allowlist. Empty allowlist or "*" allows any origin
+func IsOriginAllowed(origin string, allowedOrigins []string) bool {
+	if len(allowedOrigins) == 0 {
+		return true
+	}
+
+	for _, allowedOrigin := range allowedOrigins {
+		if allowedOrigin == "*" || allowedOrigin == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
 // validate CORS headers
-func ValidateCORS(next http.Handler) http.Handler {
+func ValidateCORS(next http.Handler, allowedOrigins []string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
 		if origin != "" {
-			// allow any origin
+			// reject origins not in allowlist
+			if !IsOriginAllowed(origin, allowedOrigins) {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+
 			w.Header().Add("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
 
 			// handle pre-flight OPTIONS request
 			if r.Method == http.MethodOptions {
@@ -11,7 +11,7 @@ import (
 func TestValidateCORSWithoutOriginHeaderInRequest(t *testing.T) {
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 
-	handlerWithJWTValidation := ValidateCORS(nextHandler)
+	handlerWithJWTValidation := ValidateCORS(nextHandler, nil)
 
 	request := httptest.NewRequest("GET", "http://testing", nil)
 	responseWriter := httptest.NewRecorder()
@@ -28,7 +28,7 @@ func TestValidateCORSWithoutOriginHeaderInRequest(t *testing.T) {
 func TestValidateCORSWithPreflightRequest(t *testing.T) {
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 
-	handlerWithJWTValidation := ValidateCORS(nextHandler)
+	handlerWithJWTValidation := ValidateCORS(nextHandler, nil)
 
 	request := httptest.NewRequest("OPTIONS", "http://testing", nil)
 	responseWriter := httptest.NewRecorder()
@@ -51,3 +51,28 @@ func TestValidateCORSWithPreflightRequest(t *testing.T) {
 
 	require.NotEmpty(response.Header.Get("Access-Control-Allow-Headers"))
 }
+
+func TestValidateCORSWithAllowedOrigins(t *testing.T) {
+	require := require.New(t)
+
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handlerWithCORSValidation := ValidateCORS(nextHandler, []string{"https://example.com"})
+
+	request := httptest.NewRequest("POST", "http://testing", nil)
+	request.Header.Add("Origin", "https://example.com")
+	responseWriter := httptest.NewRecorder()
+
+	handlerWithCORSValidation.ServeHTTP(responseWriter, request)
+
+	require.Equal(http.StatusOK, responseWriter.Code)
+	require.Equal("https://example.com", responseWriter.Header().Get("Access-Control-Allow-Origin"))
+
+	request = httptest.NewRequest("POST", "http://testing", nil)
+	request.Header.Add("Origin", "https://evil.example.com")
+	responseWriter = httptest.NewRecorder()
+
+	handlerWithCORSValidation.ServeHTTP(responseWriter, request)
+
+	require.Equal(http.StatusForbidden, responseWriter.Code)
+	require.Empty(responseWriter.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
+)
+
+// instrumentedResponseWriter records status and error code of the response
+type instrumentedResponseWriter struct {
+	http.ResponseWriter
+	status             int
+	errorCode          string
+	decodeError        bool
+	jwtRejectionReason string
+}
+
+func (w *instrumentedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *instrumentedResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// RecordErrorCode is called by route handlers with code of the error, returned to client
+func (w *instrumentedResponseWriter) RecordErrorCode(code string) {
+	w.errorCode = code
+}
+
+// RecordDecodeError is called by route handlers, if body of request can not be decoded from JSON
+func (w *instrumentedResponseWriter) RecordDecodeError() {
+	w.decodeError = true
+}
+
+// recordJWTRejection passes reason of rejected token to instrumentation, if response is instrumented
+func recordJWTRejection(w http.ResponseWriter, reason string) {
+	if instrumentedWriter, ok := w.(*instrumentedResponseWriter); ok {
+		instrumentedWriter.jwtRejectionReason = reason
+	}
+}
+
+// countingReadCloser counts bytes read from request body
+type countingReadCloser struct {
+	io.ReadCloser
+	bytesRead int
+}
+
+func (body *countingReadCloser) Read(data []byte) (int, error) {
+	length, err := body.ReadCloser.Read(data)
+	body.bytesRead += length
+	return length, err
+}
+
+// Instrument records requests to self metrics: count of requests by route, metric type, status and error code,
+// latency, size of body, JSON decode failures and JWT rejections.
+// Route and metric type of request are returned by labels, which must be limited to known routes and types
+func Instrument(
+	next http.Handler,
+	selfMetrics statsdclient.StatsdClientInterface,
+	labels func(r *http.Request) (route string, metricType string),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		instrumentedWriter := &instrumentedResponseWriter{ResponseWriter: w}
+		body := &countingReadCloser{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+
+		next.ServeHTTP(instrumentedWriter, r)
+
+		route, metricType := labels(r)
+
+		status := instrumentedWriter.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		routeTags := []statsdclient.Tag{{Key: "route", Value: route}}
+		if metricType != "" {
+			routeTags = append(routeTags, statsdclient.Tag{Key: "metric_type", Value: metricType})
+		}
+
+		requestTags := append(routeTags[:len(routeTags):len(routeTags)], statsdclient.Tag{Key: "status", Value: strconv.Itoa(status)})
+		if instrumentedWriter.errorCode != "" {
+			requestTags = append(requestTags, statsdclient.Tag{Key: "code", Value: instrumentedWriter.errorCode})
+		}
+
+		selfMetrics.Count("http.requests", 1, 1, requestTags)
+		selfMetrics.Timing("http.request_duration", float64(time.Since(start))/float64(time.Millisecond), 1, routeTags)
+		selfMetrics.Distribution("http.request_body_bytes", float64(body.bytesRead), 1, routeTags)
+
+		if instrumentedWriter.decodeError {
+			selfMetrics.Count("json.decode_errors", 1, 1, routeTags)
+		}
+
+		if instrumentedWriter.jwtRejectionReason != "" {
+			selfMetrics.Count("jwt.rejections", 1, 1, []statsdclient.Tag{{Key: "reason", Value: instrumentedWriter.jwtRejectionReason}})
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
+	"github.com/stretchr/testify/require"
+)
+
+// countingClient records counters, passed to self metrics
+type countingClient struct {
+	statsdclient.NopClient
+	counts []string
+}
+
+func (client *countingClient) Count(key string, value int, sampleRate float32, tags []statsdclient.Tag) {
+	pairs := make([]string, len(tags))
+	for i, tag := range tags {
+		pairs[i] = tag.Key + "=" + tag.Value
+	}
+
+	client.counts = append(client.counts, key+"{"+strings.Join(pairs, ",")+"}")
+}
+
+func TestInstrumentRecordsRequests(t *testing.T) {
+	require := require.New(t)
+
+	selfMetrics := &countingClient{}
+	handler := Instrument(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.(interface{ RecordErrorCode(code string) }).RecordErrorCode("invalid_body")
+			w.(interface{ RecordDecodeError() }).RecordDecodeError()
+			w.WriteHeader(http.StatusBadRequest)
+		}),
+		selfMetrics,
+		func(r *http.Request) (string, string) { return "metric", "count" },
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/count/clicks", strings.NewReader("{")))
+
+	require.Equal(
+		[]string{
+			"http.requests{route=metric,metric_type=count,status=400,code=invalid_body}",
+			"json.decode_errors{route=metric,metric_type=count}",
+		},
+		selfMetrics.counts,
+	)
+}
+
+func TestInstrumentCountsOnlyDecodeErrors(t *testing.T) {
+	selfMetrics := &countingClient{}
+	handler := Instrument(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.(interface{ RecordErrorCode(code string) }).RecordErrorCode("invalid_field")
+			w.WriteHeader(http.StatusBadRequest)
+		}),
+		selfMetrics,
+		func(r *http.Request) (string, string) { return "metric", "set" },
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/set/visitors", strings.NewReader(`{"value": {}}`)))
+
+	require.Equal(
+		t,
+		[]string{"http.requests{route=metric,metric_type=set,status=400,code=invalid_field}"},
+		selfMetrics.counts,
+	)
+}
+
+func TestInstrumentRecordsJWTRejections(t *testing.T) {
+	require := require.New(t)
+
+	selfMetrics := &countingClient{}
+	handler := Instrument(
+		ValidateJWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), VALID_TOKEN_SECTET),
+		selfMetrics,
+		func(r *http.Request) (string, string) { return "batch", "" },
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/batch", nil))
+
+	require.Equal(
+		[]string{
+			"http.requests{route=batch,status=401}",
+			"jwt.rejections{reason=missing}",
+		},
+		selfMetrics.counts,
+	)
+}
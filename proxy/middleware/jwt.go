@@ -1,10 +1,11 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/johnseekins/statsd-http-proxy/proxy/jwtkeys"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -14,8 +15,18 @@ const JwtHeaderName = "X-JWT-Token"
 
 // validate JWT middleware
 func ValidateJWT(next http.Handler, tokenSecret string) http.Handler {
+	if tokenSecret == "" {
+		return ValidateJWTWithKeySet(next, nil, ClaimRules{})
+	}
+
+	return ValidateJWTWithKeySet(next, jwtkeys.NewSecretKeySet(tokenSecret), ClaimRules{})
+}
+
+// ValidateJWTWithKeySet validates signature of JWT by keys of the set, and its claims by rules.
+// If key set is nil, all requests are accepted
+func ValidateJWTWithKeySet(next http.Handler, keySet *jwtkeys.KeySet, claimRules ClaimRules) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if tokenSecret == "" {
+		if keySet == nil {
 			next.ServeHTTP(w, r)
 		} else {
 			// get JWT from header
@@ -28,25 +39,29 @@ func ValidateJWT(next http.Handler, tokenSecret string) http.Handler {
 
 			if tokenString == "" {
 				log.Error("Token not specified")
+				recordJWTRejection(w, "missing")
 				http.Error(w, "Token not specified", 401)
 				return
 			}
 
 			// parse JWT
-			_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					log.Error("Bad signing format")
-					return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(tokenSecret), nil
-			})
+			token, err := keySet.Parse(tokenString)
 
 			if err != nil {
-				log.Error("Error parsing token")
+				log.WithFields(log.Fields{"Error": err}).Error("Error parsing token")
+				recordJWTRejection(w, "invalid")
 				http.Error(w, "Error parsing token", 403)
 				return
 			}
 
+			// validate claims
+			if claimErr := claimRules.validate(token.Claims.(jwt.MapClaims), time.Now()); claimErr != nil {
+				log.WithFields(log.Fields{"Reason": claimErr.reason}).Error("Token rejected by claims")
+				recordJWTRejection(w, claimErr.reason)
+				http.Error(w, claimErr.message, 401)
+				return
+			}
+
 			// accept request
 			next.ServeHTTP(w, r)
 		}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ClaimRules describes validation of standard claims of JWT
+type ClaimRules struct {
+	// ClockSkew is allowed difference between clocks of token issuer and the proxy
+	ClockSkew time.Duration
+	// RequireExpiry rejects tokens without "exp" claim
+	RequireExpiry bool
+	// Issuers is an allowlist of "iss" claim, any issuer is allowed if empty
+	Issuers []string
+	// Audience must be in "aud" claim, if not empty
+	Audience string
+}
+
+// maxClaimSeconds limits time claims to avoid overflow of int64
+const maxClaimSeconds = 1 << 53
+
+// claimError is a reason and a message of token rejection by claims
+type claimError struct {
+	reason  string
+	message string
+}
+
+func (err *claimError) Error() string {
+	return err.message
+}
+
+// validate checks claims of token at time now
+func (rules ClaimRules) validate(claims jwt.MapClaims, now time.Time) *claimError {
+	expiresAt, hasExpiry, err := timeClaim(claims, "exp")
+	if err != nil {
+		return &claimError{"invalid_claims", "Invalid token expiry"}
+	}
+
+	if !hasExpiry && rules.RequireExpiry {
+		return &claimError{"missing_expiry", "Token expiry not specified"}
+	}
+
+	if hasExpiry && !now.Before(expiresAt.Add(rules.ClockSkew)) {
+		return &claimError{"expired", "Token expired"}
+	}
+
+	for _, claim := range []string{"nbf", "iat"} {
+		notBefore, ok, err := timeClaim(claims, claim)
+		if err != nil {
+			return &claimError{"invalid_claims", "Invalid token " + claim}
+		}
+
+		if ok && now.Add(rules.ClockSkew).Before(notBefore) {
+			return &claimError{"not_yet_valid", "Token not valid yet"}
+		}
+	}
+
+	if len(rules.Issuers) > 0 {
+		issuer, _ := claims["iss"].(string)
+		if !containsString(rules.Issuers, issuer) {
+			return &claimError{"invalid_issuer", "Token issuer not allowed"}
+		}
+	}
+
+	if rules.Audience != "" {
+		var audiences []string
+		switch audience := claims["aud"].(type) {
+		case string:
+			audiences = []string{audience}
+		case []interface{}:
+			for _, audienceItem := range audience {
+				if audienceString, ok := audienceItem.(string); ok {
+					audiences = append(audiences, audienceString)
+				}
+			}
+		}
+
+		if !containsString(audiences, rules.Audience) {
+			return &claimError{"invalid_audience", "Token audience not allowed"}
+		}
+	}
+
+	return nil
+}
+
+// timeClaim returns time of numeric claim, which is seconds since Unix epoch
+func timeClaim(claims jwt.MapClaims, name string) (time.Time, bool, error) {
+	var seconds float64
+	switch value := claims[name].(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case float64:
+		seconds = value
+	case json.Number:
+		var err error
+		if seconds, err = value.Float64(); err != nil {
+			return time.Time{}, false, err
+		}
+	default:
+		return time.Time{}, false, errors.New("Claim is not a number")
+	}
+
+	if seconds < -maxClaimSeconds || seconds > maxClaimSeconds {
+		return time.Time{}, false, errors.New("Claim is out of range")
+	}
+
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
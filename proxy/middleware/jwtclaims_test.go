@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/johnseekins/statsd-http-proxy/proxy/jwtkeys"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimRulesValidate(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Unix(1700000000, 0)
+	rules := ClaimRules{
+		ClockSkew:     30 * time.Second,
+		RequireExpiry: true,
+		Issuers:       []string{"auth.example.com", "jobs.example.com"},
+		Audience:      "statsd-http-proxy",
+	}
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"exp": float64(now.Unix() + 60),
+			"nbf": float64(now.Unix() - 60),
+			"iat": float64(now.Unix() - 60),
+			"iss": "auth.example.com",
+			"aud": "statsd-http-proxy",
+		}
+	}
+
+	require.Nil(rules.validate(validClaims(), now))
+
+	for expectedReason, modifyClaims := range map[string]func(claims jwt.MapClaims){
+		"":                 func(claims jwt.MapClaims) { claims["aud"] = []interface{}{"other", "statsd-http-proxy"} },
+		"expired":          func(claims jwt.MapClaims) { claims["exp"] = float64(now.Unix() - 30) },
+		"missing_expiry":   func(claims jwt.MapClaims) { delete(claims, "exp") },
+		"not_yet_valid":    func(claims jwt.MapClaims) { claims["nbf"] = float64(now.Unix() + 31) },
+		"invalid_issuer":   func(claims jwt.MapClaims) { claims["iss"] = "other.example.com" },
+		"invalid_audience": func(claims jwt.MapClaims) { claims["aud"] = []interface{}{"other"} },
+		"invalid_claims":   func(claims jwt.MapClaims) { claims["exp"] = "tomorrow" },
+	} {
+		claims := validClaims()
+		modifyClaims(claims)
+
+		claimErr := rules.validate(claims, now)
+		if expectedReason == "" {
+			require.Nil(claimErr)
+		} else {
+			require.NotNil(claimErr, expectedReason)
+			require.Equal(expectedReason, claimErr.reason)
+		}
+	}
+
+	// clock skew is allowed
+	claims := validClaims()
+	claims["exp"] = json.Number("1699999980")
+	claims["nbf"] = float64(now.Unix() + 20)
+	require.Nil(rules.validate(claims, now))
+
+	// tokens without claims are accepted by default rules
+	require.Nil(ClaimRules{}.validate(jwt.MapClaims{}, now))
+}
+
+func TestValidateJWTRejectsTokenByClaims(t *testing.T) {
+	require := require.New(t)
+
+	handler := ValidateJWTWithKeySet(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		jwtkeys.NewSecretKeySet(VALID_TOKEN_SECTET),
+		ClaimRules{Audience: "statsd-http-proxy"},
+	)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-time.Hour).Unix(),
+		"aud": "statsd-http-proxy",
+	}).SignedString([]byte(VALID_TOKEN_SECTET))
+	require.NoError(err)
+
+	request := httptest.NewRequest("GET", "http://testing", nil)
+	request.Header.Add("X-JWT-Token", token)
+
+	responseWriter := httptest.NewRecorder()
+	handler.ServeHTTP(responseWriter, request)
+
+	response := responseWriter.Result()
+	responseBody, _ := ioutil.ReadAll(response.Body)
+
+	require.Equal(401, response.StatusCode)
+	require.Equal("Token expired\n", string(responseBody))
+
+	// audience of token is not the proxy
+	request = httptest.NewRequest("GET", "http://testing", nil)
+	request.Header.Add("X-JWT-Token", VALID_TOKEN)
+
+	responseWriter = httptest.NewRecorder()
+	handler.ServeHTTP(responseWriter, request)
+
+	response = responseWriter.Result()
+	responseBody, _ = ioutil.ReadAll(response.Body)
+
+	require.Equal(401, response.StatusCode)
+	require.Equal("Token audience not allowed\n", string(responseBody))
+}
@@ -0,0 +1,110 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BatchItem identifies a metric inside of a batch request.
+// Remaining fields of the item are decoded by the handler of the metric type
+type BatchItem struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+}
+
+// BatchRejection describes an item of a batch request that was not sent to StatsD
+type BatchRejection struct {
+	Index  int    `json:"index"`
+	Type   string `json:"type,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Code   string `json:"code"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// newBatchRejection describes rejected item by error of its processing
+func newBatchRejection(index int, item BatchItem, err error) BatchRejection {
+	requestError := toRequestError(err)
+
+	return BatchRejection{
+		Index:  index,
+		Type:   item.Type,
+		Key:    item.Key,
+		Code:   requestError.Code,
+		Field:  requestError.Field,
+		Reason: requestError.Message,
+	}
+}
+
+// BatchResponse lists indexes of accepted items and rejected items with reasons
+type BatchResponse struct {
+	Accepted []int            `json:"accepted"`
+	Rejected []BatchRejection `json:"rejected"`
+}
+
+// processBatchItem sends single item of a batch request to StatsD
+func (routeHandler *RouteHandler) processBatchItem(rawItem []byte) (BatchItem, error) {
+	var item BatchItem
+	if err := json.Unmarshal(rawItem, &item); err != nil {
+		return item, err
+	}
+
+	key, err := routeHandler.keyRules.apply(item.Key)
+	if err != nil {
+		return item, err
+	}
+
+	processor := routeHandler.metricProcessor(item.Type)
+	if processor == nil {
+		return item, newUnknownMetricTypeError(item.Type)
+	}
+
+	return item, processor(key, rawItem)
+}
+
+// HandleBatchRequest sends array of metrics passed in one request.
+// Newline-delimited JSON bodies are decoded as a stream, one metric per line
+func (routeHandler *RouteHandler) HandleBatchRequest(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == ndjsonContentType {
+		routeHandler.handleStreamRequest(w, r)
+		return
+	}
+
+	body, err := procBody(w, r)
+	if err != nil {
+		return
+	}
+
+	var rawItems []json.RawMessage
+	if err := json.Unmarshal(body, &rawItems); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := BatchResponse{
+		Accepted: make([]int, 0, len(rawItems)),
+		Rejected: make([]BatchRejection, 0),
+	}
+
+	for index, rawItem := range rawItems {
+		item, err := routeHandler.processBatchItem(rawItem)
+		if err != nil {
+			response.Rejected = append(response.Rejected, newBatchRejection(index, item, err))
+			continue
+		}
+
+		response.Accepted = append(response.Accepted, index)
+	}
+
+	if len(response.Rejected) > 0 {
+		log.WithFields(log.Fields{"Accepted": len(response.Accepted), "Rejected": len(response.Rejected)}).Debug("Batch items rejected")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.WithFields(log.Fields{"Error": err}).Error("Cannot write batch response")
+	}
+}
@@ -0,0 +1,80 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleBatchRequest(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	body := `[
+		{"type": "count", "key": "clicks", "value": 1, "sampleRate": 0.5},
+		{"type": "counter", "key": "clicks", "value": 1},
+		{"type": "gauge", "key": "memory", "value": 512, "tags": "env=prod"},
+		{"type": "timing", "value": 10},
+		{"type": "set", "key": "visitors", "value": {"id": "abc"}},
+		{"type": "timing", "key": "render", "value": 10}
+	]`
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleBatchRequest(responseWriter, newJSONRequest("POST", "http://testing/batch", body))
+
+	response := responseWriter.Result()
+
+	var batchResponse BatchResponse
+	require := require.New(t)
+
+	require.Equal(200, response.StatusCode)
+	require.NoError(json.NewDecoder(response.Body).Decode(&batchResponse))
+
+	require.Equal([]int{0, 2, 5}, batchResponse.Accepted)
+	require.Len(batchResponse.Rejected, 3)
+	require.Equal(1, batchResponse.Rejected[0].Index)
+	require.Equal("Unsupported metric type \"counter\"", batchResponse.Rejected[0].Reason)
+	require.Equal("unknown_metric_type", batchResponse.Rejected[0].Code)
+	require.Equal(3, batchResponse.Rejected[1].Index)
+	require.Equal("Metric key not specified", batchResponse.Rejected[1].Reason)
+	require.Equal("missing_field", batchResponse.Rejected[1].Code)
+	require.Equal("key", batchResponse.Rejected[1].Field)
+	require.Equal(4, batchResponse.Rejected[2].Index)
+	require.Equal("visitors", batchResponse.Rejected[2].Key)
+
+	require.Equal(
+		[]string{"clicks:1|c|@0.5", "memory,env=prod:512|g", "render:10|ms|@1"},
+		client.metrics,
+	)
+}
+
+func TestHandleBatchRequestWithoutPrefix(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "prefix_", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleBatchRequest(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/batch", `[{"type": "gauge", "key": "memory", "value": 512}]`),
+	)
+
+	require := require.New(t)
+
+	require.Equal(200, responseWriter.Result().StatusCode)
+	require.Equal([]string{"memory:512|g"}, client.metrics)
+}
+
+func TestHandleBatchRequestWithInvalidBody(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleBatchRequest(responseWriter, newJSONRequest("POST", "http://testing/batch", `{"type": "count"}`))
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Empty(client.metrics)
+}
@@ -0,0 +1,152 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// Codes of rejected requests
+const (
+	ErrorCodeNotFound               = "not_found"
+	ErrorCodeUnsupportedContentType = "unsupported_content_type"
+	ErrorCodeInvalidBody            = "invalid_body"
+	ErrorCodeInvalidRequest         = "invalid_request"
+	ErrorCodeInvalidField           = "invalid_field"
+	ErrorCodeMissingField           = "missing_field"
+	ErrorCodeInvalidTag             = "invalid_tag"
+	ErrorCodeInvalidKey             = "invalid_key"
+	ErrorCodeInvalidLine            = "invalid_line"
+	ErrorCodeUnknownMetricType      = "unknown_metric_type"
+)
+
+// RequestError describes why request was rejected, and is sent to client as JSON body
+type RequestError struct {
+	Status         int      `json:"-"`
+	Code           string   `json:"code"`
+	Message        string   `json:"message"`
+	Field          string   `json:"field,omitempty"`
+	SupportedTypes []string `json:"supportedTypes,omitempty"`
+	// decodeError marks errors of JSON decoding, counted by instrumentation
+	decodeError bool
+}
+
+func (err *RequestError) Error() string {
+	return err.Message
+}
+
+// newRequestError creates error of the request with status 400
+func newRequestError(code string, field string, format string, args ...interface{}) *RequestError {
+	return &RequestError{
+		Status:  http.StatusBadRequest,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Field:   field,
+	}
+}
+
+// newDecodeError creates error of the request with status 400, which body can not be decoded from JSON
+func newDecodeError(code string, field string, format string, args ...interface{}) *RequestError {
+	requestError := newRequestError(code, field, format, args...)
+	requestError.decodeError = true
+
+	return requestError
+}
+
+// newUnknownMetricTypeError creates error of the metric type, not listed in supportedMetricTypes
+func newUnknownMetricTypeError(metricType string) *RequestError {
+	return &RequestError{
+		Status:         http.StatusNotFound,
+		Code:           ErrorCodeUnknownMetricType,
+		Message:        fmt.Sprintf("Unsupported metric type %q", metricType),
+		Field:          "type",
+		SupportedTypes: supportedMetricTypes,
+	}
+}
+
+// toRequestError describes any error of request processing as RequestError
+func toRequestError(err error) *RequestError {
+	switch typedErr := err.(type) {
+	case *RequestError:
+		return typedErr
+	case *json.SyntaxError:
+		return newDecodeError(ErrorCodeInvalidBody, "", "Invalid JSON: %s", typedErr.Error())
+	case *json.UnmarshalTypeError:
+		if typedErr.Field == "" {
+			return newDecodeError(ErrorCodeInvalidBody, "", "Body must be %s, not %s", jsonTypeName(typedErr.Type), typedErr.Value)
+		}
+		return newDecodeError(ErrorCodeInvalidField, typedErr.Field, "Field %s must be %s", typedErr.Field, jsonTypeName(typedErr.Type))
+	case *statsdclient.FieldError:
+		if typedErr.Missing {
+			return newRequestError(ErrorCodeMissingField, typedErr.Field, "%s", typedErr.Message)
+		}
+		return newRequestError(ErrorCodeInvalidField, typedErr.Field, "%s", typedErr.Message)
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return newDecodeError(ErrorCodeInvalidBody, "", "Invalid JSON: unexpected end of body")
+	}
+
+	return newRequestError(ErrorCodeInvalidRequest, "", "%s", err.Error())
+}
+
+// jsonTypeName names JSON type, which is decoded to the Go type
+func jsonTypeName(goType reflect.Type) string {
+	switch goType.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Ptr:
+		return jsonTypeName(goType.Elem())
+	}
+
+	return goType.String()
+}
+
+// writeError sends error to client as JSON body
+func writeError(w http.ResponseWriter, err error) {
+	requestError := toRequestError(err)
+	recordError(w, requestError)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(requestError.Status)
+
+	if err := json.NewEncoder(w).Encode(requestError); err != nil {
+		log.WithFields(log.Fields{"Error": err}).Error("Cannot write error response")
+	}
+}
+
+// recordError passes error code to instrumentation of requests
+func recordError(w http.ResponseWriter, requestError *RequestError) {
+	if recorder, ok := w.(interface{ RecordErrorCode(code string) }); ok {
+		recorder.RecordErrorCode(requestError.Code)
+	}
+	if recorder, ok := w.(interface{ RecordDecodeError() }); ok && requestError.decodeError {
+		recorder.RecordDecodeError()
+	}
+}
+
+// HandleNotFound rejects requests to unknown routes
+func (routeHandler *RouteHandler) HandleNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, &RequestError{
+		Status:  http.StatusNotFound,
+		Code:    ErrorCodeNotFound,
+		Message: fmt.Sprintf("Route %s %s not found", r.Method, r.URL.Path),
+	})
+}
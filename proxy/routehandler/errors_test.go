@@ -0,0 +1,197 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requestErrorOf decodes error body of the response
+func requestErrorOf(t *testing.T, responseWriter *httptest.ResponseRecorder) RequestError {
+	var requestError RequestError
+
+	require.Equal(t, "application/json", responseWriter.Result().Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(responseWriter.Result().Body).Decode(&requestError))
+
+	return requestError
+}
+
+func TestHandleMetricWithUnknownMetricType(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/counter/clicks", `{"value": 1}`),
+		"counter",
+		"clicks",
+	)
+
+	require := require.New(t)
+
+	require.Equal(404, responseWriter.Result().StatusCode)
+	require.Equal(
+		RequestError{
+			Code:           "unknown_metric_type",
+			Message:        "Unsupported metric type \"counter\"",
+			Field:          "type",
+			SupportedTypes: []string{"count", "gauge", "timing", "set", "histogram", "distribution"},
+		},
+		requestErrorOf(t, responseWriter),
+	)
+	require.Empty(client.metrics)
+}
+
+func TestHandleMetricWithInvalidFieldType(t *testing.T) {
+	routeHandler := NewRouteHandler(&recordingClient{}, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/count/clicks", `{"value": "one"}`),
+		"count",
+		"clicks",
+	)
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Equal(
+		RequestError{Code: "invalid_field", Message: "Field value must be integer", Field: "value"},
+		requestErrorOf(t, responseWriter),
+	)
+}
+
+func TestHandleMetricWithFloatCount(t *testing.T) {
+	routeHandler := NewRouteHandler(&recordingClient{}, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/count/clicks", `{"value": 1.5}`),
+		"count",
+		"clicks",
+	)
+
+	require.Equal(
+		t,
+		RequestError{Code: "invalid_field", Message: "Field value must be integer", Field: "value"},
+		requestErrorOf(t, responseWriter),
+	)
+}
+
+func TestHandleMetricWithInvalidBodyType(t *testing.T) {
+	routeHandler := NewRouteHandler(&recordingClient{}, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/count/clicks", `[1]`),
+		"count",
+		"clicks",
+	)
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Equal(
+		RequestError{Code: "invalid_body", Message: "Body must be object, not array"},
+		requestErrorOf(t, responseWriter),
+	)
+}
+
+func TestHandleMetricWithInvalidJSON(t *testing.T) {
+	routeHandler := NewRouteHandler(&recordingClient{}, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/count/clicks", `{"value": `),
+		"count",
+		"clicks",
+	)
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Equal("invalid_body", requestErrorOf(t, responseWriter).Code)
+}
+
+func TestHandleMetricWithUnsupportedContentType(t *testing.T) {
+	routeHandler := NewRouteHandler(&recordingClient{}, "", KeyRules{})
+
+	request := newJSONRequest("POST", "http://testing/count/clicks", `{"value": 1}`)
+	request.Header.Set("Content-Type", "text/plain")
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(responseWriter, request, "count", "clicks")
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Equal("unsupported_content_type", requestErrorOf(t, responseWriter).Code)
+}
+
+func TestHandleNotFound(t *testing.T) {
+	routeHandler := NewRouteHandler(&recordingClient{}, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleNotFound(responseWriter, httptest.NewRequest("POST", "http://testing/unknown", nil))
+
+	require := require.New(t)
+
+	require.Equal(404, responseWriter.Result().StatusCode)
+	require.Equal(
+		RequestError{Code: "not_found", Message: "Route POST /unknown not found"},
+		requestErrorOf(t, responseWriter),
+	)
+}
+
+// errorCodeRecorder records error code, passed to instrumentation
+type errorCodeRecorder struct {
+	*httptest.ResponseRecorder
+	code        string
+	decodeError bool
+}
+
+func (recorder *errorCodeRecorder) RecordErrorCode(code string) {
+	recorder.code = code
+}
+
+func (recorder *errorCodeRecorder) RecordDecodeError() {
+	recorder.decodeError = true
+}
+
+func TestWriteErrorRecordsErrorCode(t *testing.T) {
+	recorder := &errorCodeRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	writeError(recorder, newRequestError(ErrorCodeInvalidTag, "tags", "Invalid tag"))
+
+	require.Equal(t, ErrorCodeInvalidTag, recorder.code)
+}
+
+func TestWriteErrorRecordsDecodeErrors(t *testing.T) {
+	require := require.New(t)
+
+	var value struct{ Value int }
+
+	for _, err := range []error{
+		json.Unmarshal([]byte(`{"value": `), &value),
+		json.Unmarshal([]byte(`{"value": "one"}`), &value),
+		json.NewDecoder(strings.NewReader(`{"value": 1`)).Decode(&value),
+	} {
+		recorder := &errorCodeRecorder{ResponseRecorder: httptest.NewRecorder()}
+		writeError(recorder, err)
+		require.True(recorder.decodeError, err.Error())
+	}
+
+	// invalid values of decoded fields are not decode errors
+	recorder := &errorCodeRecorder{ResponseRecorder: httptest.NewRecorder()}
+	writeError(recorder, newRequestError(ErrorCodeInvalidField, "value", "Set member must be a string or a number"))
+	require.Equal(ErrorCodeInvalidField, recorder.code)
+	require.False(recorder.decodeError)
+}
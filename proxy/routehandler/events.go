@@ -0,0 +1,92 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
+)
+
+type EventRequest struct {
+	Title          string `json:"title"`
+	Text           string `json:"text"`
+	Timestamp      int64  `json:"timestamp,omitempty"`
+	Hostname       string `json:"hostname,omitempty"`
+	AggregationKey string `json:"aggregationKey,omitempty"`
+	Priority       string `json:"priority,omitempty"`
+	SourceType     string `json:"sourceType,omitempty"`
+	AlertType      string `json:"alertType,omitempty"`
+	Tags           Tags   `json:"tags,omitempty"`
+}
+
+// HandleEventRequest sends DogStatsD event
+func (routeHandler *RouteHandler) HandleEventRequest(w http.ResponseWriter, r *http.Request) {
+	body, err := procBody(w, r)
+	if err != nil {
+		return
+	}
+
+	var req EventRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	event := statsdclient.Event{
+		Title:          req.Title,
+		Text:           req.Text,
+		Timestamp:      req.Timestamp,
+		Hostname:       req.Hostname,
+		AggregationKey: req.AggregationKey,
+		Priority:       req.Priority,
+		SourceType:     req.SourceType,
+		AlertType:      req.AlertType,
+		Tags:           req.Tags,
+	}
+
+	if err := event.Validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	routeHandler.statsdClient.Event(event)
+}
+
+type ServiceCheckRequest struct {
+	Name      string `json:"name"`
+	Status    int    `json:"status"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Tags      Tags   `json:"tags,omitempty"`
+}
+
+// HandleServiceCheckRequest sends DogStatsD service check
+func (routeHandler *RouteHandler) HandleServiceCheckRequest(w http.ResponseWriter, r *http.Request) {
+	body, err := procBody(w, r)
+	if err != nil {
+		return
+	}
+
+	var req ServiceCheckRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	check := statsdclient.ServiceCheck{
+		Name:      req.Name,
+		Status:    req.Status,
+		Timestamp: req.Timestamp,
+		Hostname:  req.Hostname,
+		Message:   req.Message,
+		Tags:      req.Tags,
+	}
+
+	if err := check.Validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	routeHandler.statsdClient.ServiceCheck(check)
+}
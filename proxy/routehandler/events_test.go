@@ -0,0 +1,69 @@
+package routehandler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleEventRequest(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleEventRequest(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/event", `{"title": "Deploy", "text": "v1.2", "alertType": "info", "tags": "env=prod,app=web"}`),
+	)
+
+	require := require.New(t)
+
+	require.Equal(200, responseWriter.Result().StatusCode)
+	require.Equal([]string{"event:Deploy:info:env:prod,app:web"}, client.metrics)
+}
+
+func TestHandleEventRequestWithInvalidEvent(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	for body, expectedError := range map[string]RequestError{
+		`{"text": "v1.2"}`:                         {Code: "missing_field", Message: "Event title not specified", Field: "title"},
+		`{"title": "Deploy", "priority": "high"}`:  {Code: "invalid_field", Message: "Invalid event priority \"high\"", Field: "priority"},
+		`{"title": "Deploy", "hostname": "web|1"}`: {Code: "invalid_field", Message: "Field hostname must not contain '|' or line breaks", Field: "hostname"},
+	} {
+		responseWriter := httptest.NewRecorder()
+		routeHandler.HandleEventRequest(responseWriter, newJSONRequest("POST", "http://testing/event", body))
+
+		require.Equal(t, 400, responseWriter.Result().StatusCode, body)
+		require.Equal(t, expectedError, requestErrorOf(t, responseWriter), body)
+	}
+
+	require.Empty(t, client.metrics)
+}
+
+func TestHandleServiceCheckRequest(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleServiceCheckRequest(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/service_check", `{"name": "app.can_connect", "status": 2, "tags": "env=prod"}`),
+	)
+
+	responseWriter = httptest.NewRecorder()
+	routeHandler.HandleServiceCheckRequest(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/service_check", `{"name": "app.can_connect", "status": 4}`),
+	)
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Equal(
+		RequestError{Code: "invalid_field", Message: "Invalid service check status 4", Field: "status"},
+		requestErrorOf(t, responseWriter),
+	)
+	require.Equal([]string{"service_check:app.can_connect:2:env:prod"}, client.metrics)
+}
@@ -1,33 +1,27 @@
 package routehandler
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"strings"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
 )
 
-type CountRequest struct {
-	Value    int    `json:"value,omitempty"`
-	Tags string `json:"tags,omitempty"`
-	SampleRate float64 `json:"sampleRate"`
-}
-
 const maxBodySize = 2000 * 1024 * 1024
 
 func procBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
 	if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
-		http.Error(w, "Unsupported content type", 400)
-		return []byte(""), fmt.Errorf("Unsupported content type")
+		err := newRequestError(ErrorCodeUnsupportedContentType, "", "Unsupported content type")
+		writeError(w, err)
+		return []byte(""), err
 	}
 
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		writeError(w, newRequestError(ErrorCodeInvalidBody, "", "%s", err.Error()))
 		return []byte(""), err
 	}
 	r.Body.Close()
@@ -35,117 +29,119 @@ func procBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
 	return body, nil
 }
 
-func (routeHandler *RouteHandler) handleCountRequest(w http.ResponseWriter, r *http.Request, key string) {
-	body, err := procBody(w, r)
-	if err != nil {
-		return
-	}
+type CountRequest struct {
+	Value      int     `json:"value,omitempty"`
+	Tags       Tags    `json:"tags,omitempty"`
+	SampleRate float64 `json:"sampleRate"`
+}
+
+func (routeHandler *RouteHandler) processCountRequest(key string, body []byte) error {
 	var req CountRequest
 	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+		return err
 	}
 
-    key += processTags(req.Tags)	
-
 	var sampleRate float64 = 1
 	if req.SampleRate != 0 {
 		sampleRate = float64(req.SampleRate)
 	}
-	routeHandler.statsdClient.Count(key, req.Value, float32(sampleRate))
+	routeHandler.statsdClient.Count(key, req.Value, float32(sampleRate), req.Tags)
+
+	return nil
 }
 
 type GaugeRequest struct {
-	Value int    `json:"value,omitempty"`
-	Tags  string `json:"tags,omitempty"`
+	Value float64 `json:"value,omitempty"`
+	Tags  Tags    `json:"tags,omitempty"`
+	Delta bool    `json:"delta,omitempty"`
 }
 
-func (routeHandler *RouteHandler) handleGaugeRequest(w http.ResponseWriter, r *http.Request, key string) {
-	body, err := procBody(w, r)
-	if err != nil {
-		return
-	}
-
+func (routeHandler *RouteHandler) processGaugeRequest(key string, body []byte) error {
 	var req GaugeRequest
 	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+		return err
 	}
 
-    key += processTags(req.Tags)	
+	if req.Delta {
+		routeHandler.statsdClient.GaugeShift(key, req.Value, req.Tags)
+	} else {
+		routeHandler.statsdClient.Gauge(key, req.Value, req.Tags)
+	}
 
-	routeHandler.statsdClient.Gauge(key, req.Value)
+	return nil
 }
 
+// TimingRequest is a request of timing, histogram and distribution metrics
 type TimingRequest struct {
-	Value int64    `json:"value,omitempty"`
-	Tags     string `json:"tags,omitempty"`
+	Value      float64 `json:"value,omitempty"`
+	Tags       Tags    `json:"tags,omitempty"`
 	SampleRate float64 `json:"sampleRate"`
 }
 
-func (routeHandler *RouteHandler) handleTimingRequest(w http.ResponseWriter, r *http.Request, key string) {
-	body, err := procBody(w, r)
-	if err != nil {
-		return
-	}
+// sampledValueProcessor returns the function that decodes request of sampled metric
+// and sends it with the given client method, like Timing, Histogram or Distribution
+func sampledValueProcessor(
+	send func(key string, value float64, sampleRate float32, tags []statsdclient.Tag),
+) func(key string, body []byte) error {
+	return func(key string, body []byte) error {
+		var req TimingRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
 
-	var req TimingRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+		var sampleRate float64 = 1
+		if req.SampleRate != 0 {
+			sampleRate = float64(req.SampleRate)
+		}
+
+		send(key, req.Value, float32(sampleRate), req.Tags)
+
+		return nil
 	}
+}
 
-    key += processTags(req.Tags)	
+// SetMember is a member of the set, passed as JSON string or number
+type SetMember string
 
-	var sampleRate float64 = 1
-	if req.SampleRate != 0 {
-		sampleRate = float64(req.SampleRate)
+func (member *SetMember) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
 	}
 
+	switch typedValue := value.(type) {
+	case string:
+		*member = SetMember(typedValue)
+	case json.Number:
+		*member = SetMember(typedValue.String())
+	default:
+		return newRequestError(ErrorCodeInvalidField, "value", "Set member must be a string or a number")
+	}
 
-	routeHandler.statsdClient.Timing(key, req.Value, float32(sampleRate))
+	return nil
 }
 
 type SetRequest struct {
-	Value int `json:"value,omitempty"`
-	Tags  string `json:"tags,omitempty"`
+	Value SetMember `json:"value,omitempty"`
+	Tags  Tags      `json:"tags,omitempty"`
 }
 
-func (routeHandler *RouteHandler) handleSetRequest(w http.ResponseWriter, r *http.Request, key string) {
-	body, err := procBody(w, r)
-	if err != nil {
-		return
-	}
-
+func (routeHandler *RouteHandler) processSetRequest(key string, body []byte) error {
 	var req SetRequest
 	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+		return err
 	}
 
-    key += processTags(req.Tags)	
-
-	routeHandler.statsdClient.Set(key, req.Value)
-}
-
-func processTags(tagsList string) string {
-	list := strings.Split(strings.TrimSpace(tagsList), ",")
-	if len(list) == 0 {
-		return ""
+	if req.Value == "" {
+		return newRequestError(ErrorCodeMissingField, "value", "Set member not specified")
 	}
-
-	for _, pair := range list {
-		pairItems := strings.Split(pair, "=")
-		if len(pairItems) != 2 {
-			log.WithFields(log.Fields{"Tags": tagsList, "pair": pairItems}).Debug("Missing pair")
-			return ""
-		} else if len(strings.TrimSpace(pairItems[0])) == 0 {
-			log.WithFields(log.Fields{"Tags": tagsList, "pair": pairItems}).Debug("Invalid tag key")
-			return ""
-		} else if len(strings.TrimSpace(pairItems[1])) == 0 {
-			log.WithFields(log.Fields{"Tags": tagsList, "pair": pairItems}).Debug("Invalid tag value")
-			return ""
-		}
+	if err := statsdclient.ValidateSetMember(string(req.Value)); err != nil {
+		return err
 	}
-	return "," + tagsList
+
+	routeHandler.statsdClient.Set(key, string(req.Value), req.Tags)
+
+	return nil
 }
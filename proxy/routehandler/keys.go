@@ -0,0 +1,65 @@
+package routehandler
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Modes of metric key validation
+const (
+	// KeyModeStrict rejects requests with keys, containing forbidden characters
+	KeyModeStrict = "strict"
+	// KeyModeLenient replaces forbidden characters in keys with "_"
+	KeyModeLenient = "lenient"
+)
+
+// forbiddenKeyCharacters break metric line if passed in key,
+// or are taken for tag separators by one of supported tag formats
+const forbiddenKeyCharacters = ":|@#,=;[]"
+
+// KeyRules define how metric keys are validated before sending to StatsD
+type KeyRules struct {
+	Mode      string
+	MaxLength int
+}
+
+// ValidateKeyMode checks that key validation mode is supported
+func ValidateKeyMode(mode string) error {
+	if mode != KeyModeStrict && mode != KeyModeLenient {
+		return fmt.Errorf("Unsupported key validation mode %q", mode)
+	}
+
+	return nil
+}
+
+func isForbiddenKeyCharacter(character rune) bool {
+	return strings.ContainsRune(forbiddenKeyCharacters, character) || unicode.IsSpace(character) || unicode.IsControl(character)
+}
+
+// apply validates key, and in lenient mode replaces forbidden characters
+func (rules KeyRules) apply(key string) (string, error) {
+	if key == "" {
+		return "", newRequestError(ErrorCodeMissingField, "key", "Metric key not specified")
+	}
+
+	if rules.MaxLength > 0 && utf8.RuneCountInString(key) > rules.MaxLength {
+		return "", newRequestError(ErrorCodeInvalidKey, "key", "Metric key must not be longer than %d characters", rules.MaxLength)
+	}
+
+	if strings.IndexFunc(key, isForbiddenKeyCharacter) == -1 {
+		return key, nil
+	}
+
+	if rules.Mode != KeyModeLenient {
+		return "", newRequestError(ErrorCodeInvalidKey, "key", "Metric key %q must not contain whitespace or any of %q", key, forbiddenKeyCharacters)
+	}
+
+	return strings.Map(func(character rune) rune {
+		if isForbiddenKeyCharacter(character) {
+			return '_'
+		}
+		return character
+	}, key), nil
+}
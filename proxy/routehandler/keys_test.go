@@ -0,0 +1,87 @@
+package routehandler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRulesApply(t *testing.T) {
+	strictRules := KeyRules{Mode: KeyModeStrict, MaxLength: 10}
+	lenientRules := KeyRules{Mode: KeyModeLenient, MaxLength: 10}
+
+	require := require.New(t)
+
+	for _, rules := range []KeyRules{strictRules, lenientRules} {
+		key, err := rules.apply("page.load")
+		require.NoError(err)
+		require.Equal("page.load", key)
+
+		_, err = rules.apply("")
+		require.EqualError(err, "Metric key not specified")
+
+		_, err = rules.apply("page.load.time")
+		require.EqualError(err, "Metric key must not be longer than 10 characters")
+	}
+
+	for _, key := range []string{"a:b", "a|b", "a@b", "a#b", "a,b", "a=b", "a;b", "a[b", "a]b", "a\nb", "a b", "a\tb"} {
+		_, err := strictRules.apply(key)
+		require.Error(err, key)
+		require.Equal(ErrorCodeInvalidKey, toRequestError(err).Code)
+
+		sanitizedKey, err := lenientRules.apply(key)
+		require.NoError(err, key)
+		require.Equal("a_b", sanitizedKey)
+	}
+
+	key, err := KeyRules{}.apply(strings.Repeat("a", 1000))
+	require.NoError(err)
+	require.Len(key, 1000)
+
+	_, err = KeyRules{}.apply("a|b")
+	require.Error(err)
+}
+
+func TestValidateKeyMode(t *testing.T) {
+	require.NoError(t, ValidateKeyMode("strict"))
+	require.NoError(t, ValidateKeyMode("lenient"))
+	require.Error(t, ValidateKeyMode("relaxed"))
+}
+
+func TestHandleMetricWithInvalidKey(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{Mode: KeyModeStrict})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/count/cli%7Ccks", `{"value": 1}`),
+		"count",
+		"cli|cks",
+	)
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Equal(
+		RequestError{Code: "invalid_key", Message: "Metric key \"cli|cks\" must not contain whitespace or any of \":|@#,=;[]\"", Field: "key"},
+		requestErrorOf(t, responseWriter),
+	)
+	require.Empty(client.metrics)
+}
+
+func TestHandleMetricWithSanitizedKey(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{Mode: KeyModeLenient})
+
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/count/cli%7Ccks", `{"value": 1}`),
+		"count",
+		"cli|cks",
+	)
+
+	require.Equal(t, []string{"cli_cks:1|c|@1"}, client.metrics)
+}
@@ -0,0 +1,117 @@
+package routehandler
+
+import (
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const rawContentType = "text/plain"
+
+// rawMetricTypes lists metric types accepted in StatsD line format
+var rawMetricTypes = map[string]bool{
+	"c":  true,
+	"g":  true,
+	"ms": true,
+	"s":  true,
+	"h":  true,
+	"d":  true,
+}
+
+// rawValuePattern matches plain decimal values, so NaN, Inf and hex floats are not passed to StatsD
+var rawValuePattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+
+// parseRawMetric validates metric in StatsD line format "key:value|type|@sampleRate|#tags"
+func parseRawMetric(line string) (BatchItem, error) {
+	var item BatchItem
+
+	separatorPosition := strings.Index(line, ":")
+	if separatorPosition == -1 {
+		return item, newRequestError(ErrorCodeInvalidLine, "", "Value separator not found")
+	}
+
+	item.Key = line[:separatorPosition]
+	if strings.TrimSpace(item.Key) == "" {
+		return item, newRequestError(ErrorCodeInvalidLine, "", "Metric key not specified")
+	}
+
+	sections := strings.Split(line[separatorPosition+1:], "|")
+	if len(sections) < 2 {
+		return item, newRequestError(ErrorCodeInvalidLine, "", "Metric type not specified")
+	}
+
+	item.Type = sections[1]
+	if !rawMetricTypes[item.Type] {
+		return item, newUnknownMetricTypeError(item.Type)
+	}
+
+	value := sections[0]
+	if value == "" {
+		return item, newRequestError(ErrorCodeInvalidLine, "", "Metric value not specified")
+	}
+	if item.Type != "s" {
+		if !rawValuePattern.MatchString(value) {
+			return item, newRequestError(ErrorCodeInvalidLine, "", "Invalid metric value %q", value)
+		}
+		if number, err := strconv.ParseFloat(value, 64); err != nil || math.IsInf(number, 0) {
+			return item, newRequestError(ErrorCodeInvalidLine, "", "Invalid metric value %q", value)
+		}
+	}
+
+	hasSampleRate, hasTags := false, false
+	for _, section := range sections[2:] {
+		switch {
+		case strings.HasPrefix(section, "@") && !hasSampleRate:
+			sampleRate, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil || sampleRate <= 0 || sampleRate > 1 {
+				return item, newRequestError(ErrorCodeInvalidLine, "", "Invalid sample rate %q", section[1:])
+			}
+			hasSampleRate = true
+		case strings.HasPrefix(section, "#") && !hasTags:
+			if len(section) == 1 {
+				return item, newRequestError(ErrorCodeInvalidLine, "", "Empty tags section")
+			}
+			// tags are validated by the same rules as tags of JSON requests
+			if _, err := parseTagPairs(strings.Split(section[1:], ",")); err != nil {
+				return item, err
+			}
+			hasTags = true
+		default:
+			return item, newRequestError(ErrorCodeInvalidLine, "", "Unexpected section %q", section)
+		}
+	}
+
+	return item, nil
+}
+
+// processRawMetric validates metric line, prefixes its key and passes rest of the line to StatsD as is
+func (routeHandler *RouteHandler) processRawMetric(line []byte) (BatchItem, error) {
+	metric := string(line)
+
+	item, err := parseRawMetric(metric)
+	if err != nil {
+		return item, err
+	}
+
+	key, err := routeHandler.keyRules.apply(item.Key)
+	if err != nil {
+		return item, err
+	}
+
+	routeHandler.statsdClient.Raw(routeHandler.metricPrefix + key + metric[len(item.Key):])
+
+	return item, nil
+}
+
+// HandleRawRequest sends metrics passed in StatsD line format, one metric per line
+func (routeHandler *RouteHandler) HandleRawRequest(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != rawContentType {
+		writeError(w, newRequestError(ErrorCodeUnsupportedContentType, "", "Unsupported content type"))
+		return
+	}
+
+	processStream(w, r, routeHandler.processRawMetric)
+}
@@ -0,0 +1,121 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRawMetric(t *testing.T) {
+	validMetrics := []string{
+		"clicks:1|c",
+		"clicks:-1|c|@0.1",
+		"memory:+12.5|g",
+		"render:120|ms|@0.5|#env:prod,page:home",
+		"visitors:f81d4fae-7dec|s",
+		"latency,env=prod:12|h",
+		"latency:12|d|#env:prod",
+		"latency:.5|d|#env=prod",
+		"memory:-3.|g",
+	}
+
+	for _, metric := range validMetrics {
+		_, err := parseRawMetric(metric)
+		require.NoError(t, err, metric)
+	}
+
+	invalidMetrics := []string{
+		"clicks",
+		":1|c",
+		"clicks:1",
+		"clicks:|c",
+		"clicks:abc|c",
+		"clicks:1|counter",
+		"clicks:1|c|@0",
+		"clicks:1|c|@2",
+		"clicks:1|c|@0.1|@0.1",
+		"clicks:1|c|#",
+		"clicks:1|c|x",
+		"clicks:NaN|c",
+		"clicks:Inf|c",
+		"clicks:+Inf|g",
+		"clicks:0x1p3|c",
+		"clicks:1e3|c",
+		"clicks:" + strings.Repeat("9", 400) + "|g",
+		"clicks:1|c|#env",
+		"clicks:1|c|#env:prod,page:home:1",
+		"clicks:1|c|#env:pr od",
+		"clicks:1|c|#env:prod;x",
+	}
+
+	for _, metric := range invalidMetrics {
+		_, err := parseRawMetric(metric)
+		require.Error(t, err, metric)
+	}
+}
+
+func TestHandleRawRequest(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "prefix_", KeyRules{})
+
+	body := "clicks:1|c|@0.1|#env:prod\nclicks:abc|c\n\nmemory:512|g\n"
+
+	request := httptest.NewRequest("POST", "http://testing/raw", strings.NewReader(body))
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleRawRequest(responseWriter, request)
+
+	response := responseWriter.Result()
+
+	var streamResponse StreamResponse
+	require := require.New(t)
+
+	require.Equal(200, response.StatusCode)
+	require.NoError(json.NewDecoder(response.Body).Decode(&streamResponse))
+
+	require.Equal(2, streamResponse.Accepted)
+	require.Equal(1, streamResponse.Rejected)
+	require.Equal(1, streamResponse.Rejections[0].Index)
+	require.Equal("Invalid metric value \"abc\"", streamResponse.Rejections[0].Reason)
+
+	require.Equal(
+		[]string{"prefix_clicks:1|c|@0.1|#env:prod", "prefix_memory:512|g"},
+		client.metrics,
+	)
+}
+
+func TestHandleRawRequestWithKeyRules(t *testing.T) {
+	body := "cli|cks:1|c\nmemory:512|g\n"
+
+	for mode, expectedMetrics := range map[string][]string{
+		KeyModeStrict:  {"memory:512|g"},
+		KeyModeLenient: {"cli_cks:1|c", "memory:512|g"},
+	} {
+		client := &recordingClient{}
+		routeHandler := NewRouteHandler(client, "", KeyRules{Mode: mode})
+
+		request := httptest.NewRequest("POST", "http://testing/raw", strings.NewReader(body))
+		request.Header.Set("Content-Type", "text/plain")
+
+		routeHandler.HandleRawRequest(httptest.NewRecorder(), request)
+
+		require.Equal(t, expectedMetrics, client.metrics, mode)
+	}
+}
+
+func TestHandleRawRequestWithUnsupportedContentType(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleRawRequest(responseWriter, newJSONRequest("POST", "http://testing/raw", "clicks:1|c"))
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Empty(client.metrics)
+}
@@ -11,37 +11,85 @@ import (
 type RouteHandler struct {
 	statsdClient statsdclient.StatsdClientInterface
 	metricPrefix string
+	keyRules     KeyRules
 }
 
 // NewRouteHandler creates collection of route handlers
 func NewRouteHandler(
 	statsdClient statsdclient.StatsdClientInterface,
 	metricPrefix string,
+	keyRules KeyRules,
 ) *RouteHandler {
 	// build route handler
 	routeHandler := RouteHandler{
 		statsdClient,
 		metricPrefix,
+		keyRules,
 	}
 
 	return &routeHandler
 }
 
+// supportedMetricTypes lists metric types, accepted by HandleMetric
+var supportedMetricTypes = []string{"count", "gauge", "timing", "set", "histogram", "distribution"}
+
+// IsSupportedMetricType checks if metric type is accepted by HandleMetric
+func IsSupportedMetricType(metricType string) bool {
+	for _, supportedMetricType := range supportedMetricTypes {
+		if metricType == supportedMetricType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// metricProcessor returns the function that decodes and sends a metric of the given type,
+// or nil if the type is not supported
+func (routeHandler *RouteHandler) metricProcessor(metricType string) func(key string, body []byte) error {
+	switch metricType {
+	case "count":
+		return routeHandler.processCountRequest
+	case "gauge":
+		return routeHandler.processGaugeRequest
+	case "timing":
+		return sampledValueProcessor(routeHandler.statsdClient.Timing)
+	case "set":
+		return routeHandler.processSetRequest
+	case "histogram":
+		return sampledValueProcessor(routeHandler.statsdClient.Histogram)
+	case "distribution":
+		return sampledValueProcessor(routeHandler.statsdClient.Distribution)
+	}
+
+	return nil
+}
+
 func (routeHandler *RouteHandler) HandleMetric(
 	w http.ResponseWriter,
 	r *http.Request,
 	metricType string,
 	metricKey string,
 ) {
-	switch metricType {
-	case "count":
-		routeHandler.handleCountRequest(w, r, metricKey)
-	case "gauge":
-		routeHandler.handleGaugeRequest(w, r, metricKey)
-	case "timing":
-		routeHandler.handleTimingRequest(w, r, metricKey)
-	case "set":
-		routeHandler.handleSetRequest(w, r, metricKey)
+	processor := routeHandler.metricProcessor(metricType)
+	if processor == nil {
+		writeError(w, newUnknownMetricTypeError(metricType))
+		return
+	}
+
+	metricKey, err := routeHandler.keyRules.apply(metricKey)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	body, err := procBody(w, r)
+	if err != nil {
+		return
+	}
+
+	if err := processor(metricKey, body); err != nil {
+		writeError(w, err)
 	}
 }
 
@@ -0,0 +1,234 @@
+package routehandler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingClient stores sent metrics in StatsD line format with InfluxDB tags
+type recordingClient struct {
+	metrics []string
+}
+
+// recordingKey appends tags to key in InfluxDB format
+func recordingKey(key string, tags []statsdclient.Tag) string {
+	for _, tag := range tags {
+		key += "," + tag.Key + "=" + tag.Value
+	}
+
+	return key
+}
+
+// recordingTags joins tags in DogStatsD format
+func recordingTags(tags []statsdclient.Tag) string {
+	pairs := make([]string, len(tags))
+	for i, tag := range tags {
+		pairs[i] = tag.Key + ":" + tag.Value
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (client *recordingClient) Open()  {}
+func (client *recordingClient) Close() {}
+
+func (client *recordingClient) Count(key string, value int, sampleRate float32, tags []statsdclient.Tag) {
+	client.metrics = append(client.metrics, fmt.Sprintf("%s:%d|c|@%g", recordingKey(key, tags), value, sampleRate))
+}
+
+func (client *recordingClient) Timing(key string, time float64, sampleRate float32, tags []statsdclient.Tag) {
+	client.metrics = append(client.metrics, fmt.Sprintf("%s:%g|ms|@%g", recordingKey(key, tags), time, sampleRate))
+}
+
+func (client *recordingClient) Gauge(key string, value float64, tags []statsdclient.Tag) {
+	client.metrics = append(client.metrics, fmt.Sprintf("%s:%g|g", recordingKey(key, tags), value))
+}
+
+func (client *recordingClient) GaugeShift(key string, value float64, tags []statsdclient.Tag) {
+	client.metrics = append(client.metrics, fmt.Sprintf("%s:%+g|g", recordingKey(key, tags), value))
+}
+
+func (client *recordingClient) Set(key string, value string, tags []statsdclient.Tag) {
+	client.metrics = append(client.metrics, fmt.Sprintf("%s:%s|s", recordingKey(key, tags), value))
+}
+
+func (client *recordingClient) Histogram(key string, value float64, sampleRate float32, tags []statsdclient.Tag) {
+	client.metrics = append(client.metrics, fmt.Sprintf("%s:%g|h|@%g", recordingKey(key, tags), value, sampleRate))
+}
+
+func (client *recordingClient) Distribution(key string, value float64, sampleRate float32, tags []statsdclient.Tag) {
+	client.metrics = append(client.metrics, fmt.Sprintf("%s:%g|d|@%g", recordingKey(key, tags), value, sampleRate))
+}
+
+func (client *recordingClient) Event(event statsdclient.Event) {
+	client.metrics = append(client.metrics, fmt.Sprintf("event:%s:%s:%s", event.Title, event.AlertType, recordingTags(event.Tags)))
+}
+
+func (client *recordingClient) ServiceCheck(check statsdclient.ServiceCheck) {
+	client.metrics = append(client.metrics, fmt.Sprintf("service_check:%s:%d:%s", check.Name, check.Status, recordingTags(check.Tags)))
+}
+
+func (client *recordingClient) Raw(metric string) {
+	client.metrics = append(client.metrics, metric)
+}
+
+func newJSONRequest(method string, target string, body string) *http.Request {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	return request
+}
+
+func TestHandleMetric(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/count/some.key", `{"value": 3, "tags": "env=prod"}`),
+		"count",
+		"some.key",
+	)
+
+	require := require.New(t)
+
+	require.Equal(200, responseWriter.Result().StatusCode)
+	require.Equal([]string{"some.key,env=prod:3|c|@1"}, client.metrics)
+}
+
+func TestHandleMetricWithoutPrefix(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "prefix_", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/gauge/some.key", `{"value": 3}`),
+		"gauge",
+		"some.key",
+	)
+
+	require := require.New(t)
+
+	require.Equal(200, responseWriter.Result().StatusCode)
+	// metric prefix is applied only to raw lines
+	require.Equal([]string{"some.key:3|g"}, client.metrics)
+}
+
+func TestHandleMetricWithFloatValues(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/gauge/cls", `{"value": 0.0125}`),
+		"gauge",
+		"cls",
+	)
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/timing/fid", `{"value": 0.35}`),
+		"timing",
+		"fid",
+	)
+
+	require.Equal(t, []string{"cls:0.0125|g", "fid:0.35|ms|@1"}, client.metrics)
+}
+
+func TestHandleMetricWithGaugeDelta(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/gauge/queue", `{"value": 3, "delta": true}`),
+		"gauge",
+		"queue",
+	)
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/gauge/queue", `{"value": -3}`),
+		"gauge",
+		"queue",
+	)
+
+	require.Equal(t, []string{"queue:+3|g", "queue:-3|g"}, client.metrics)
+}
+
+func TestHandleMetricWithSetMembers(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	statusCodes := []int{}
+	for _, body := range []string{
+		`{"value": "f81d4fae-7dec-11d0-a765-00a0c91e6bf6"}`,
+		`{"value": 42}`,
+		`{"value": "user:42"}`,
+		`{"value": "user\r42"}`,
+		`{"value": "user#42"}`,
+		`{"value": true}`,
+		`{}`,
+	} {
+		responseWriter := httptest.NewRecorder()
+		routeHandler.HandleMetric(
+			responseWriter,
+			newJSONRequest("POST", "http://testing/set/visitors", body),
+			"set",
+			"visitors",
+		)
+		statusCodes = append(statusCodes, responseWriter.Result().StatusCode)
+	}
+
+	require := require.New(t)
+
+	require.Equal([]int{200, 200, 400, 400, 400, 400, 400}, statusCodes)
+	require.Equal(
+		[]string{"visitors:f81d4fae-7dec-11d0-a765-00a0c91e6bf6|s", "visitors:42|s"},
+		client.metrics,
+	)
+}
+
+func TestHandleMetricWithHistogramAndDistribution(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/histogram/latency", `{"value": 12.5, "sampleRate": 0.5}`),
+		"histogram",
+		"latency",
+	)
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/distribution/latency", `{"value": 13, "tags": "env=prod"}`),
+		"distribution",
+		"latency",
+	)
+
+	require.Equal(t, []string{"latency:12.5|h|@0.5", "latency,env=prod:13|d|@1"}, client.metrics)
+}
+
+func TestHandleMetricWithInvalidBody(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/gauge/some.key", `{"value": "abc"}`),
+		"gauge",
+		"some.key",
+	)
+
+	require := require.New(t)
+
+	require.Equal(400, responseWriter.Result().StatusCode)
+	require.Empty(client.metrics)
+}
@@ -0,0 +1,112 @@
+package routehandler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// maxStreamLineSize limits length of single metric line in the stream
+const maxStreamLineSize = 64 * 1024
+
+// maxStreamRejections limits number of rejected lines reported back to client
+const maxStreamRejections = 100
+
+// StreamResponse counts accepted and rejected lines of a stream,
+// describes first rejected lines, and error of reading the rest of body
+type StreamResponse struct {
+	Accepted   int              `json:"accepted"`
+	Rejected   int              `json:"rejected"`
+	Rejections []BatchRejection `json:"rejections"`
+	Error      *RequestError    `json:"error,omitempty"`
+}
+
+func (response *StreamResponse) reject(rejection BatchRejection) {
+	response.Rejected++
+	if len(response.Rejections) < maxStreamRejections {
+		response.Rejections = append(response.Rejections, rejection)
+	}
+}
+
+// readStreamLine reads next line of the stream.
+// Lines longer than maxStreamLineSize are skipped and reported as bufio.ErrBufferFull
+func readStreamLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+
+	// skip rest of the long line
+	for err == bufio.ErrBufferFull {
+		_, err = reader.ReadSlice('\n')
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return nil, bufio.ErrBufferFull
+}
+
+// handleStreamRequest sends metrics from newline-delimited JSON body without buffering whole body
+func (routeHandler *RouteHandler) handleStreamRequest(w http.ResponseWriter, r *http.Request) {
+	processStream(w, r, routeHandler.processBatchItem)
+}
+
+// processStream passes every non-empty line of the body to processLine
+// and writes counts of accepted and rejected lines to response
+func processStream(w http.ResponseWriter, r *http.Request, processLine func(line []byte) (BatchItem, error)) {
+	defer r.Body.Close()
+
+	reader := bufio.NewReaderSize(io.LimitReader(r.Body, maxBodySize), maxStreamLineSize)
+
+	response := StreamResponse{
+		Rejections: make([]BatchRejection, 0),
+	}
+
+	for index := 0; ; index++ {
+		line, err := readStreamLine(reader)
+		if err == bufio.ErrBufferFull {
+			response.reject(newBatchRejection(index, BatchItem{}, newRequestError(ErrorCodeInvalidLine, "", "Line too long")))
+			continue
+		}
+		// lines before the failure are already sent, so they are counted in response
+		if err != nil && err != io.EOF {
+			response.Error = newRequestError(ErrorCodeInvalidBody, "", "%s", err.Error())
+			break
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			item, processErr := processLine(line)
+			if processErr != nil {
+				response.reject(newBatchRejection(index, item, processErr))
+			} else {
+				response.Accepted++
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if response.Rejected > 0 {
+		log.WithFields(log.Fields{"Accepted": response.Accepted, "Rejected": response.Rejected}).Debug("Stream lines rejected")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Error != nil {
+		recordError(w, response.Error)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(response.Error.Status)
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.WithFields(log.Fields{"Error": err}).Error("Cannot write stream response")
+	}
+}
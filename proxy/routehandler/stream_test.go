@@ -0,0 +1,108 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleStreamRequest(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	body := strings.Join([]string{
+		`{"type": "count", "key": "clicks", "value": 1}`,
+		`{"type": "count", "key": `,
+		``,
+		`{"type": "gauge", "key": "memory", "value": 512}`,
+		`{"type": "gauge", "key": "` + strings.Repeat("a", maxStreamLineSize) + `", "value": 1}`,
+		`{"type": "counter", "key": "latency", "value": 1}`,
+		`{"type": "timing", "key": "render", "value": 10}`,
+	}, "\n")
+
+	request := httptest.NewRequest("POST", "http://testing/batch", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-ndjson")
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleBatchRequest(responseWriter, request)
+
+	response := responseWriter.Result()
+
+	var streamResponse StreamResponse
+	require := require.New(t)
+
+	require.Equal(200, response.StatusCode)
+	require.NoError(json.NewDecoder(response.Body).Decode(&streamResponse))
+
+	require.Equal(3, streamResponse.Accepted)
+	require.Equal(3, streamResponse.Rejected)
+	require.Len(streamResponse.Rejections, 3)
+	require.Equal(1, streamResponse.Rejections[0].Index)
+	require.Equal(4, streamResponse.Rejections[1].Index)
+	require.Equal("Line too long", streamResponse.Rejections[1].Reason)
+	require.Equal(5, streamResponse.Rejections[2].Index)
+	require.Equal("latency", streamResponse.Rejections[2].Key)
+
+	require.Equal(
+		[]string{"clicks:1|c|@1", "memory:512|g", "render:10|ms|@1"},
+		client.metrics,
+	)
+}
+
+func TestHandleStreamRequestWithCharset(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	request := httptest.NewRequest("POST", "http://testing/batch", strings.NewReader(`{"type": "count", "key": "clicks", "value": 1}`))
+	request.Header.Set("Content-Type", "application/x-ndjson; charset=utf-8")
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleBatchRequest(responseWriter, request)
+
+	response := responseWriter.Result()
+
+	var streamResponse StreamResponse
+	require := require.New(t)
+
+	require.Equal(200, response.StatusCode)
+	require.NoError(json.NewDecoder(response.Body).Decode(&streamResponse))
+
+	require.Equal(1, streamResponse.Accepted)
+	require.Equal([]string{"clicks:1|c|@1"}, client.metrics)
+}
+
+func TestHandleStreamRequestWithReadError(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	body := io.MultiReader(
+		strings.NewReader(`{"type": "count", "key": "clicks", "value": 1}`+"\n"),
+		iotest.ErrReader(errors.New("connection reset")),
+	)
+
+	request := httptest.NewRequest("POST", "http://testing/batch", body)
+	request.Header.Set("Content-Type", "application/x-ndjson")
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleBatchRequest(responseWriter, request)
+
+	response := responseWriter.Result()
+
+	var streamResponse StreamResponse
+	require := require.New(t)
+
+	require.Equal(400, response.StatusCode)
+	require.NoError(json.NewDecoder(response.Body).Decode(&streamResponse))
+
+	require.Equal(1, streamResponse.Accepted)
+	require.Equal(0, streamResponse.Rejected)
+	require.NotNil(streamResponse.Error)
+	require.Equal(ErrorCodeInvalidBody, streamResponse.Error.Code)
+	require.Equal([]string{"clicks:1|c|@1"}, client.metrics)
+}
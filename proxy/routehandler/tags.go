@@ -0,0 +1,139 @@
+package routehandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
+)
+
+// forbiddenTagCharacters break metric line in one of supported tag formats
+const forbiddenTagCharacters = ",=:|#;[]@"
+
+// Tags of the metric, passed as comma-separated "key=value" string,
+// as object {"key": "value"} or as array ["key:value"]
+type Tags []statsdclient.Tag
+
+func (tags *Tags) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	var err error
+	switch data[0] {
+	case '"':
+		var tagsList string
+		if err = json.Unmarshal(data, &tagsList); err == nil {
+			*tags, err = parseTagsList(tagsList)
+		}
+	case '{':
+		*tags, err = parseTagsObject(data)
+	case '[':
+		var pairs []string
+		if err = json.Unmarshal(data, &pairs); err != nil {
+			return newRequestError(ErrorCodeInvalidTag, "tags", "Tags array must contain strings")
+		}
+		*tags, err = parseTagPairs(pairs)
+	case 'n':
+		*tags = nil
+	default:
+		err = newRequestError(ErrorCodeInvalidTag, "tags", "Tags must be a string, an object or an array")
+	}
+
+	return err
+}
+
+// parseTagsList parses comma-separated key=value pairs
+func parseTagsList(tagsList string) (Tags, error) {
+	if strings.TrimSpace(tagsList) == "" {
+		return nil, nil
+	}
+
+	return parseTagPairs(strings.Split(tagsList, ","))
+}
+
+// parseTagPairs parses pairs, separated by ":" or "="
+func parseTagPairs(pairs []string) (Tags, error) {
+	tags := make(Tags, 0, len(pairs))
+	for _, pair := range pairs {
+		separatorPosition := strings.IndexAny(pair, ":=")
+		if separatorPosition == -1 {
+			return nil, newRequestError(ErrorCodeInvalidTag, "tags", "Invalid tag %q: missing value", pair)
+		}
+
+		tag, err := newTag(pair[:separatorPosition], pair[separatorPosition+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// parseTagsObject parses object of tags, keeping order of keys
+func parseTagsObject(data []byte) (Tags, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	// skip opening brace
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	tags := make(Tags, 0)
+	for decoder.More() {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := keyToken.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		var tag statsdclient.Tag
+		switch typedValue := value.(type) {
+		case string:
+			tag, err = newTag(key, typedValue)
+		case json.Number:
+			tag, err = newTag(key, typedValue.String())
+		case bool:
+			tag, err = newTag(key, fmt.Sprint(typedValue))
+		default:
+			err = newRequestError(ErrorCodeInvalidTag, "tags", "Invalid tag %q: value must be a string, a number or a boolean", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// newTag validates that key and value of the tag are safe to send in any tag format
+func newTag(key string, value string) (statsdclient.Tag, error) {
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	name := key + "=" + value
+
+	if key == "" {
+		return statsdclient.Tag{}, newRequestError(ErrorCodeInvalidTag, "tags", "Invalid tag %q: empty key", name)
+	}
+	if value == "" {
+		return statsdclient.Tag{}, newRequestError(ErrorCodeInvalidTag, "tags", "Invalid tag %q: empty value", name)
+	}
+	if strings.ContainsAny(key+value, forbiddenTagCharacters) || strings.IndexFunc(key+value, unicode.IsSpace) != -1 {
+		return statsdclient.Tag{}, newRequestError(ErrorCodeInvalidTag, "tags", "Invalid tag %q: tags must not contain whitespace or any of %q", name, forbiddenTagCharacters)
+	}
+
+	return statsdclient.Tag{Key: key, Value: value}, nil
+}
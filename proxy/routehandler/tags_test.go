@@ -0,0 +1,89 @@
+package routehandler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagsUnmarshalJSON(t *testing.T) {
+	expectedTags := Tags{{Key: "env", Value: "prod"}, {Key: "locale", Value: "en-us"}}
+
+	for _, data := range []string{
+		`"env=prod,locale=en-us"`,
+		`" env = prod , locale=en-us "`,
+		`{"env": "prod", "locale": "en-us"}`,
+		`["env:prod", "locale:en-us"]`,
+		`["env=prod", "locale=en-us"]`,
+	} {
+		var tags Tags
+		require.NoError(t, json.Unmarshal([]byte(data), &tags), data)
+		require.Equal(t, expectedTags, tags, data)
+	}
+
+	var tags Tags
+	require.NoError(t, json.Unmarshal([]byte(`{"build": 42, "canary": true}`), &tags))
+	require.Equal(t, Tags{{Key: "build", Value: "42"}, {Key: "canary", Value: "true"}}, tags)
+
+	for _, data := range []string{`""`, `null`, `{}`, `[]`} {
+		var tags Tags
+		require.NoError(t, json.Unmarshal([]byte(data), &tags), data)
+		require.Empty(t, tags, data)
+	}
+}
+
+func TestTagsUnmarshalJSONWithInvalidTags(t *testing.T) {
+	for data, expectedError := range map[string]string{
+		`"env=prod,locale"`:         `Invalid tag "locale": missing value`,
+		`"env=prod,=en-us"`:         `Invalid tag "=en-us": empty key`,
+		`{"env": ""}`:               `Invalid tag "env=": empty value`,
+		`{"env": {"name": "prod"}}`: `Invalid tag "env": value must be a string, a number or a boolean`,
+		`["env:pr|od"]`:             `Invalid tag "env=pr|od": tags must not contain whitespace or any of ",=:|#;[]@"`,
+		`{"en v": "prod"}`:          `Invalid tag "en v=prod": tags must not contain whitespace or any of ",=:|#;[]@"`,
+		`[1]`:                       `Tags array must contain strings`,
+		`42`:                        `Tags must be a string, an object or an array`,
+	} {
+		var tags Tags
+		err := json.Unmarshal([]byte(data), &tags)
+		require.EqualError(t, err, expectedError, data)
+	}
+}
+
+func TestHandleMetricWithInvalidTags(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	responseWriter := httptest.NewRecorder()
+	routeHandler.HandleMetric(
+		responseWriter,
+		newJSONRequest("POST", "http://testing/count/clicks", `{"value": 1, "tags": "env=prod,locale"}`),
+		"count",
+		"clicks",
+	)
+
+	response := responseWriter.Result()
+
+	var requestError RequestError
+	require := require.New(t)
+
+	require.Equal(400, response.StatusCode)
+	require.NoError(json.NewDecoder(response.Body).Decode(&requestError))
+	require.Equal(RequestError{Code: "invalid_tag", Message: "Invalid tag \"locale\": missing value", Field: "tags"}, requestError)
+	require.Empty(client.metrics)
+}
+
+func TestHandleMetricWithTagsObject(t *testing.T) {
+	client := &recordingClient{}
+	routeHandler := NewRouteHandler(client, "", KeyRules{})
+
+	routeHandler.HandleMetric(
+		httptest.NewRecorder(),
+		newJSONRequest("POST", "http://testing/count/clicks", `{"value": 1, "tags": {"env": "prod", "page": "home"}}`),
+		"count",
+		"clicks",
+	)
+
+	require.Equal(t, []string{"clicks,env=prod,page=home:1|c|@1"}, client.metrics)
+}
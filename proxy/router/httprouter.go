@@ -3,15 +3,23 @@ package router
 import (
 	"net/http"
 
+	"github.com/johnseekins/statsd-http-proxy/proxy/jwtkeys"
 	"github.com/johnseekins/statsd-http-proxy/proxy/middleware"
 	"github.com/johnseekins/statsd-http-proxy/proxy/routehandler"
+	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
 	"github.com/julienschmidt/httprouter"
 )
 
-// NewHTTPRouter creates julienschmidt's HTTP router
+// NewHTTPRouter creates julienschmidt's HTTP router.
+// Metrics handler is served on "/metrics" with JWT validation if passed. Requests are recorded to self metrics.
+// If tokenKeys is nil, JWT is not validated, otherwise its claims are validated by claimRules
 func NewHTTPRouter(
 	routeHandler *routehandler.RouteHandler,
-	tokenSecret string,
+	tokenKeys *jwtkeys.KeySet,
+	claimRules middleware.ClaimRules,
+	allowedOrigins []string,
+	metricsHandler http.Handler,
+	selfMetrics statsdclient.StatsdClientInterface,
 ) http.Handler {
 	// build router
 	router := httprouter.New()
@@ -20,28 +28,107 @@ func NewHTTPRouter(
 	router.Handler(
 		http.MethodGet,
 		"/heartbeat",
-		middleware.ValidateCORS(http.HandlerFunc(routeHandler.HandleHeartbeatRequest)))
+		middleware.Instrument(
+			middleware.ValidateCORS(http.HandlerFunc(routeHandler.HandleHeartbeatRequest), allowedOrigins),
+			selfMetrics,
+			staticRouteLabels("heartbeat"),
+		),
+	)
+
+	if metricsHandler != nil {
+		router.Handler(
+			http.MethodGet,
+			"/metrics",
+			middleware.Instrument(
+				middleware.ValidateCORS(middleware.ValidateJWTWithKeySet(metricsHandler, tokenKeys, claimRules), allowedOrigins),
+				selfMetrics,
+				staticRouteLabels("metrics"),
+			),
+		)
+	}
+
+	metricHandler := middleware.ValidateCORS(
+		middleware.ValidateJWTWithKeySet(
+			http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					// get variables from path
+					params := httprouter.ParamsFromContext(r.Context())
+					metricType := params.ByName("type")
+					metricKeySuffix := params.ByName("key")
+
+					routeHandler.HandleMetric(w, r, metricType, metricKeySuffix)
+				},
+			),
+			tokenKeys,
+			claimRules,
+		),
+		allowedOrigins,
+	)
 
 	router.Handler(
 		http.MethodPost,
 		"/:type/:key",
-		middleware.ValidateCORS(
-			middleware.ValidateJWT(
-				http.HandlerFunc(
-					func(w http.ResponseWriter, r *http.Request) {
-						// get variables from path
-						params := httprouter.ParamsFromContext(r.Context())
-						metricType := params.ByName("type")
-						metricKeySuffix := params.ByName("key")
-
-						routeHandler.HandleMetric(w, r, metricType, metricKeySuffix)
-					},
-				),
-				tokenSecret,
+		middleware.Instrument(
+			metricHandler,
+			selfMetrics,
+			func(r *http.Request) (string, string) {
+				metricType := httprouter.ParamsFromContext(r.Context()).ByName("type")
+				if !routehandler.IsSupportedMetricType(metricType) {
+					metricType = "unknown"
+				}
+
+				return "metric", metricType
+			},
+		),
+	)
+
+	// httprouter does not allow static segments next to the ":type" wildcard,
+	// so single segment routes are dispatched by name
+	singleSegmentHandlers := map[string]http.HandlerFunc{
+		"batch":         routeHandler.HandleBatchRequest,
+		"raw":           routeHandler.HandleRawRequest,
+		"event":         routeHandler.HandleEventRequest,
+		"service_check": routeHandler.HandleServiceCheckRequest,
+	}
+
+	singleSegmentHandler := middleware.ValidateCORS(
+		middleware.ValidateJWTWithKeySet(
+			http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					params := httprouter.ParamsFromContext(r.Context())
+
+					if handler, ok := singleSegmentHandlers[params.ByName("type")]; ok {
+						handler(w, r)
+					} else {
+						routeHandler.HandleNotFound(w, r)
+					}
+				},
 			),
+			tokenKeys,
+			claimRules,
+		),
+		allowedOrigins,
+	)
+
+	router.Handler(
+		http.MethodPost,
+		"/:type",
+		middleware.Instrument(
+			singleSegmentHandler,
+			selfMetrics,
+			func(r *http.Request) (string, string) {
+				route := httprouter.ParamsFromContext(r.Context()).ByName("type")
+				if _, ok := singleSegmentHandlers[route]; !ok {
+					route = "unknown"
+				}
+
+				return route, ""
+			},
 		),
 	)
 
+	router.NotFound = middleware.Instrument(http.HandlerFunc(routeHandler.HandleNotFound), selfMetrics, staticRouteLabels("unknown"))
+
 	// Handle pre-flight CORS requests
 	router.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Access-Control-Request-Method") == "" {
@@ -49,8 +136,9 @@ func NewHTTPRouter(
 		}
 
 		origin := r.Header.Get("Origin")
-		if origin != "" {
+		if origin != "" && middleware.IsOriginAllowed(origin, allowedOrigins) {
 			w.Header().Add("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
 			w.Header().Add("Access-Control-Allow-Headers", middleware.JwtHeaderName+", X-Requested-With, Origin, Accept, Content-Type, Authentication")
 			w.Header().Add("Access-Control-Allow-Methods", "GET, POST, HEAD, OPTIONS")
 		}
@@ -61,3 +149,10 @@ func NewHTTPRouter(
 
 	return router
 }
+
+// staticRouteLabels labels requests of the route without metric type
+func staticRouteLabels(route string) func(r *http.Request) (string, string) {
+	return func(r *http.Request) (string, string) {
+		return route, ""
+	}
+}
@@ -2,102 +2,311 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/johnseekins/statsd-http-proxy/proxy/routehandler"
-	"github.com/johnseekins/statsd-http-proxy/proxy/router"
+	"github.com/johnseekins/statsd-http-proxy/proxy/certstore"
+	"github.com/johnseekins/statsd-http-proxy/proxy/middleware"
 	"github.com/johnseekins/statsd-http-proxy/proxy/statsdclient"
 	log "github.com/sirupsen/logrus"
 )
 
+// BackendModeMirror sends every metric to all StatsD backends
+const BackendModeMirror = "mirror"
+
+// BackendModeHash routes every metric key to one of StatsD backends by consistent hash ring
+const BackendModeHash = "hash"
+
+// BackendModeNone does not send metrics to StatsD, like when they are only exposed to Prometheus
+const BackendModeNone = "none"
+
+// healthCheckTimeout limits time of health check of StatsD backend
+const healthCheckTimeout = time.Second
+
 // Server is a proxy server between HTTP REST API and UDP Connection to StatsD
 type Server struct {
 	httpAddress string
 	httpServer  *http.Server
-	statsdClient statsdclient.StatsdClientInterface
-	tlsCert     string
-	tlsKey      string
+	certStore   *certstore.Store
+	adminServer *http.Server
+
+	// self metrics and Prometheus metrics are kept between reloads
+	selfMetrics      statsdclient.StatsdClientInterface
+	prometheusClient *statsdclient.PrometheusClient
+
+	// config is reloaded by loadConfig, replacing current generation
+	loadConfig         func() (Config, error)
+	config             Config
+	generationLock     sync.RWMutex
+	generation         *generation
+	retiredGenerations sync.WaitGroup
 }
 
-// NewServer creates new instance of StatsD HTTP Proxy
-func NewServer(
-	httpHost string,
-	httpPort int,
-	httpReadTimeout int,
-	httpWriteTimeout int,
-	httpIdleTimeout int,
-	statsdHost string,
-	statsdPort int,
-	tlsCert string,
-	tlsKey string,
-	metricPrefix string,
-	tokenSecret string,
-	verbose bool,
-) *Server {
-	// prepare metric prefix
-	if metricPrefix != "" && (metricPrefix)[len(metricPrefix)-1:] != "_" {
-		metricPrefix = metricPrefix + "_"
-	}
-
-	// create StatsD Client
-	statsdClient := statsdclient.NewGoMetricClient(statsdHost, statsdPort)
-
-	// build route handler
-	routeHandler := routehandler.NewRouteHandler(
-		statsdClient,
-		metricPrefix,
-	)
-
-	// build router
-	httpServerHandler := router.NewHTTPRouter(routeHandler, tokenSecret)
-
-	// get HTTP server address to bind
-	httpAddress := fmt.Sprintf("%s:%d", httpHost, httpPort)
+// NewServer creates new instance of StatsD HTTP Proxy from config.
+// On SIGHUP config is loaded again by loadConfig
+func NewServer(config Config, loadConfig func() (Config, error)) *Server {
+	proxyServer := &Server{
+		httpAddress: fmt.Sprintf("%s:%d", config.HTTPHost, config.HTTPPort),
+		selfMetrics: statsdclient.NopClient{},
+		loadConfig:  loadConfig,
+		config:      config,
+	}
+
+	// load TLS certificates, reloaded when renewed
+	var certPairs []certstore.Pair
+	if len(config.TLSCert) > 0 && len(config.TLSKey) > 0 {
+		certPairs = append(certPairs, certstore.Pair{CertFile: config.TLSCert, KeyFile: config.TLSKey})
+	}
+
+	for _, pairString := range config.TLSPairs {
+		pair, err := certstore.ParsePair(pairString)
+		if err != nil {
+			log.WithFields(log.Fields{"Error": err}).Fatal("Invalid TLS certificate pair")
+		}
+
+		certPairs = append(certPairs, pair)
+	}
+
+	if len(certPairs) > 0 {
+		certStore, err := certstore.NewStore(certPairs, time.Duration(config.TLSReloadInterval)*time.Second)
+		if err != nil {
+			log.WithFields(log.Fields{"Error": err}).Fatal("Cannot load TLS certificates")
+		}
+
+		proxyServer.certStore = certStore
+	}
+
+	// authenticate TLS clients by certificates
+	var clientAuth tls.ClientAuthType
+	var clientCAs *x509.CertPool
+	if config.TLSClientCA != "" {
+		if proxyServer.certStore == nil {
+			log.Fatal("Client CA bundle requires TLS certificate")
+		}
+
+		var err error
+		if clientAuth, err = certstore.ParseClientAuth(config.TLSClientAuth); err != nil {
+			log.WithFields(log.Fields{"Error": err}).Fatal("Invalid TLS client authentication")
+		}
+
+		if clientCAs, err = certstore.LoadCertPool(config.TLSClientCA); err != nil {
+			log.WithFields(log.Fields{"Error": err}).Fatal("Cannot load client CA bundle")
+		}
+	}
+
+	// collect self metrics of the proxy
+	var selfMetricsClients []statsdclient.StatsdClientInterface
+	if config.AdminPort > 0 {
+		selfMetricsRegistry := statsdclient.NewPrometheusClient(nil, 0)
+		selfMetricsClients = append(selfMetricsClients, selfMetricsRegistry)
+
+		adminRouter := http.NewServeMux()
+		adminRouter.Handle("/metrics", selfMetricsRegistry)
+
+		proxyServer.adminServer = &http.Server{
+			Addr:         fmt.Sprintf("%s:%d", config.AdminHost, config.AdminPort),
+			Handler:      adminRouter,
+			ReadTimeout:  time.Duration(config.HTTPReadTimeout) * time.Second,
+			WriteTimeout: time.Duration(config.HTTPWriteTimeout) * time.Second,
+			IdleTimeout:  time.Duration(config.HTTPIdleTimeout) * time.Second,
+		}
+	}
+
+	if internalMetricPrefix := config.InternalMetricPrefix; internalMetricPrefix != "" {
+		if config.InternalMetricInterval <= 0 {
+			log.WithFields(log.Fields{"Interval": config.InternalMetricInterval}).Fatal("Invalid interval of internal metrics")
+		}
+
+		if internalMetricPrefix[len(internalMetricPrefix)-1:] != "." {
+			internalMetricPrefix = internalMetricPrefix + "."
+		}
+
+		// self metrics are aggregated, so they are sent to StatsD once per interval
+		selfMetricsClients = append(selfMetricsClients, statsdclient.NewAggregator(
+			statsdclient.NopClient{},
+			&backendSink{proxyServer, internalMetricPrefix},
+			time.Duration(config.InternalMetricInterval)*time.Second,
+			[]float64{90},
+			0,
+			false,
+		))
+	}
+
+	switch len(selfMetricsClients) {
+	case 0:
+	case 1:
+		proxyServer.selfMetrics = selfMetricsClients[0]
+	default:
+		proxyServer.selfMetrics = statsdclient.NewMultiClient(selfMetricsClients...)
+	}
+
+	// expose metrics to Prometheus
+	if config.PrometheusEnabled {
+		buckets, err := parseFloatList(config.PrometheusBuckets)
+		if err != nil {
+			log.WithFields(log.Fields{"Error": err}).Fatal("Invalid Prometheus buckets")
+		}
+
+		proxyServer.prometheusClient = statsdclient.NewPrometheusClient(buckets, config.PrometheusMaxSeries)
+		proxyServer.prometheusClient.SetDroppedSeriesHandler(func(name string) {
+			proxyServer.selfMetrics.Count("prometheus.dropped_series", 1, 1, nil)
+		})
+	}
+
+	// build StatsD client and HTTP handler
+	initialGeneration, err := proxyServer.newGeneration(config)
+	if err != nil {
+		log.WithFields(log.Fields{"Error": err}).Fatal("Invalid configuration")
+	}
+	proxyServer.generation = initialGeneration
 
 	// create http server
-	httpServer := &http.Server{
-		Addr:           httpAddress,
-		Handler:        httpServerHandler,
-		ReadTimeout:    time.Duration(httpReadTimeout) * time.Second,
-		WriteTimeout:   time.Duration(httpWriteTimeout) * time.Second,
-		IdleTimeout:    time.Duration(httpIdleTimeout) * time.Second,
+	proxyServer.httpServer = &http.Server{
+		Addr:           proxyServer.httpAddress,
+		Handler:        proxyServer,
+		ReadTimeout:    time.Duration(config.HTTPReadTimeout) * time.Second,
+		WriteTimeout:   time.Duration(config.HTTPWriteTimeout) * time.Second,
+		IdleTimeout:    time.Duration(config.HTTPIdleTimeout) * time.Second,
 		MaxHeaderBytes: 1 << 11,
 	}
 
-	statsdHTTPProxyServer := Server{
-		httpAddress,
-		httpServer,
-		statsdClient,
-		tlsCert,
-		tlsKey,
+	if proxyServer.certStore != nil {
+		proxyServer.httpServer.TLSConfig = &tls.Config{
+			GetCertificate: proxyServer.certStore.GetCertificate,
+			ClientAuth:     clientAuth,
+			ClientCAs:      clientCAs,
+		}
 	}
 
-	return &statsdHTTPProxyServer
+	if clientCAs != nil {
+		proxyServer.httpServer.Handler = middleware.ClientCertificate(proxyServer, clientCAs)
+	}
+
+	return proxyServer
+}
+
+// Reload loads config again, and replaces StatsD client and HTTP handler without closing listeners.
+// Requests, handled by previous configuration, are completed before its StatsD client is closed
+func (proxyServer *Server) Reload() {
+	reloadedConfig, err := proxyServer.loadConfig()
+	if err != nil {
+		log.WithFields(log.Fields{"Error": err}).Error("Cannot reload configuration")
+		return
+	}
+
+	if !reflect.DeepEqual(reloadedConfig.restartOptions(), proxyServer.config.restartOptions()) {
+		log.Warn("Changed options of listeners, TLS, admin server, self metrics and Prometheus are applied on restart")
+	}
+
+	// keep running values of options, applied on restart only, so config describes what is applied
+	config := proxyServer.config
+	config.applyReloadOptions(reloadedConfig)
+
+	newGeneration, err := proxyServer.newGeneration(config)
+	if err != nil {
+		log.WithFields(log.Fields{"Error": err}).Error("Cannot reload configuration")
+		return
+	}
+
+	newGeneration.open()
+
+	proxyServer.generationLock.Lock()
+	retiredGeneration := proxyServer.generation
+	proxyServer.generation = newGeneration
+	proxyServer.config = config
+	proxyServer.generationLock.Unlock()
+
+	// close StatsD client of previous configuration, when its requests are handled
+	proxyServer.retiredGenerations.Add(1)
+	go func() {
+		defer proxyServer.retiredGenerations.Done()
+
+		retiredGeneration.inFlight.Wait()
+		retiredGeneration.close()
+	}()
+
+	log.Info("Configuration reloaded")
+}
+
+// parseFloatList parses comma-separated list of numbers, like "90,99.9"
+func parseFloatList(list string) ([]float64, error) {
+	var values []float64
+	for _, valueString := range strings.Split(list, ",") {
+		valueString = strings.TrimSpace(valueString)
+		if valueString == "" {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(valueString, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %q", valueString)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// validatePercentiles checks that percentiles are in (0, 100] range
+func validatePercentiles(percentiles []float64) error {
+	for _, percentile := range percentiles {
+		if percentile <= 0 || percentile > 100 {
+			return fmt.Errorf("Invalid percentile %g", percentile)
+		}
+	}
+
+	return nil
 }
 
 // Listen starts listening HTTP connections
 func (proxyServer *Server) Listen() {
-	// prepare for gracefull shutdown
+	// prepare for gracefull shutdown and reload
 	gracefullStopSignalHandler := make(chan os.Signal, 1)
 	signal.Notify(gracefullStopSignalHandler, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
+	reloadSignalHandler := make(chan os.Signal, 1)
+	signal.Notify(reloadSignalHandler, syscall.SIGHUP)
+
+	// open StatsD connection
+	proxyServer.generation.open()
+	proxyServer.selfMetrics.Open()
+
+	// start admin server with self metrics
+	if proxyServer.adminServer != nil {
+		go func() {
+			log.WithFields(log.Fields{"Address": proxyServer.adminServer.Addr}).Info("Starting admin server")
+
+			err := proxyServer.adminServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				log.WithFields(log.Fields{"Error": err}).Fatal("Cannot start admin server")
+			}
+		}()
+	}
+
+	// watch renewal of TLS certificates
+	if proxyServer.certStore != nil {
+		proxyServer.certStore.Watch()
+	}
+
 	// start HTTP/HTTPS proxy to StatsD
 	go func() {
 		log.WithFields(log.Fields{"Address": proxyServer.httpAddress}).Info("Starting HTTP server")
 
-		// open StatsD connection
-		proxyServer.statsdClient.Open()
-		defer proxyServer.statsdClient.Close()
-
 		// open HTTP connection
 		var err error
-		if len(proxyServer.tlsCert) > 0 && len(proxyServer.tlsKey) > 0 {
-			err = proxyServer.httpServer.ListenAndServeTLS(proxyServer.tlsCert, proxyServer.tlsKey)
+		if proxyServer.certStore != nil {
+			err = proxyServer.httpServer.ListenAndServeTLS("", "")
 		} else {
 			err = proxyServer.httpServer.ListenAndServe()
 		}
@@ -107,7 +316,18 @@ func (proxyServer *Server) Listen() {
 		}
 	}()
 
-	<-gracefullStopSignalHandler
+	// reload configuration until stopped
+	func() {
+		for {
+			select {
+			case <-reloadSignalHandler:
+				log.Info("Reloading configuration")
+				proxyServer.Reload()
+			case <-gracefullStopSignalHandler:
+				return
+			}
+		}
+	}()
 
 	// Gracefull shutdown
 	log.Info("Stopping HTTP server")
@@ -121,5 +341,20 @@ func (proxyServer *Server) Listen() {
 		log.WithFields(log.Fields{"error": err}).Fatal("HTTP Server Shutdown Failed")
 	}
 
+	if proxyServer.adminServer != nil {
+		if err := proxyServer.adminServer.Shutdown(ctx); err != nil {
+			log.WithFields(log.Fields{"error": err}).Error("Admin Server Shutdown Failed")
+		}
+	}
+
+	if proxyServer.certStore != nil {
+		proxyServer.certStore.Close()
+	}
+
+	// send buffered metrics of handled requests and close StatsD connection
+	proxyServer.selfMetrics.Close()
+	proxyServer.retiredGenerations.Wait()
+	proxyServer.generation.close()
+
 	log.Info("HTTP server stopped successfully")
 }
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStatsdServer(t *testing.T) (*net.UDPConn, int) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func readTestPacket(t *testing.T, conn *net.UDPConn) string {
+	buffer := make([]byte, 1024)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	length, err := conn.Read(buffer)
+	require.NoError(t, err)
+
+	return string(buffer[:length])
+}
+
+func sendTestCount(proxyServer *Server, origin string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/count/clicks", strings.NewReader(`{"value": 1}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Origin", origin)
+
+	recorder := httptest.NewRecorder()
+	proxyServer.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestServerReloadsConfig(t *testing.T) {
+	require := require.New(t)
+
+	statsdServer, statsdPort := newTestStatsdServer(t)
+	reloadedStatsdServer, reloadedStatsdPort := newTestStatsdServer(t)
+
+	config := DefaultConfig()
+	config.StatsdPort = statsdPort
+	config.CORSAllowedOrigins = []string{"https://before.example.com"}
+
+	reloadedConfig := config
+	reloadedConfig.StatsdPort = reloadedStatsdPort
+	reloadedConfig.CORSAllowedOrigins = []string{"https://after.example.com"}
+
+	proxyServer := NewServer(config, func() (Config, error) { return reloadedConfig, nil })
+	proxyServer.generation.statsdClient.Open()
+	defer func() { proxyServer.generation.statsdClient.Close() }()
+
+	require.Equal(http.StatusOK, sendTestCount(proxyServer, "https://before.example.com").Code)
+	require.Equal("clicks:1|c", readTestPacket(t, statsdServer))
+
+	// in-flight request keeps previous configuration until it is handled
+	inFlightGeneration := proxyServer.acquireGeneration()
+
+	proxyServer.Reload()
+
+	require.Equal(http.StatusForbidden, sendTestCount(proxyServer, "https://before.example.com").Code)
+	require.Equal(http.StatusOK, sendTestCount(proxyServer, "https://after.example.com").Code)
+	require.Equal("clicks:1|c", readTestPacket(t, reloadedStatsdServer))
+
+	inFlightGeneration.statsdClient.Count("clicks", 2, 1, nil)
+	require.Equal("clicks:2|c", readTestPacket(t, statsdServer))
+	inFlightGeneration.inFlight.Done()
+
+	proxyServer.retiredGenerations.Wait()
+}
+
+func TestServerKeepsConfigOnInvalidReload(t *testing.T) {
+	require := require.New(t)
+
+	statsdServer, statsdPort := newTestStatsdServer(t)
+
+	config := DefaultConfig()
+	config.StatsdPort = statsdPort
+
+	reloadedConfig := config
+	reloadedConfig.KeyMode = "unknown"
+
+	proxyServer := NewServer(config, func() (Config, error) { return reloadedConfig, nil })
+	proxyServer.generation.statsdClient.Open()
+	defer func() { proxyServer.generation.statsdClient.Close() }()
+
+	currentGeneration := proxyServer.generation
+	proxyServer.Reload()
+	require.Same(currentGeneration, proxyServer.generation)
+
+	require.Equal(http.StatusOK, sendTestCount(proxyServer, "").Code)
+	require.Equal("clicks:1|c", readTestPacket(t, statsdServer))
+}
+
+func TestServerExposesPrometheusMetrics(t *testing.T) {
+	require := require.New(t)
+
+	config := DefaultConfig()
+	config.StatsdBackendMode = BackendModeNone
+	config.PrometheusEnabled = true
+
+	proxyServer := NewServer(config, func() (Config, error) { return config, nil })
+
+	require.Equal(http.StatusOK, sendTestCount(proxyServer, "").Code)
+
+	recorder := httptest.NewRecorder()
+	proxyServer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(http.StatusOK, recorder.Code)
+	require.Equal("# TYPE clicks_total counter\nclicks_total 1\n", recorder.Body.String())
+}
+
+func TestServerPacksUDPMetricsUpToMaxPacketSize(t *testing.T) {
+	require := require.New(t)
+
+	statsdServer, statsdPort := newTestStatsdServer(t)
+
+	config := DefaultConfig()
+	config.StatsdPort = statsdPort
+	config.StatsdFlushInterval = 60000
+	config.StatsdMaxPacketSize = 25
+
+	proxyServer := NewServer(config, func() (Config, error) { return config, nil })
+	proxyServer.generation.statsdClient.Open()
+
+	for i := 0; i < 3; i++ {
+		require.Equal(http.StatusOK, sendTestCount(proxyServer, "").Code)
+	}
+	require.Equal("clicks:1|c\nclicks:1|c", readTestPacket(t, statsdServer))
+
+	proxyServer.generation.statsdClient.Close()
+	require.Equal("clicks:1|c", readTestPacket(t, statsdServer))
+}
+
+func TestServerCountsUDPSendErrors(t *testing.T) {
+	require := require.New(t)
+
+	statsdServer, statsdPort := newTestStatsdServer(t)
+	statsdServer.Close()
+
+	config := DefaultConfig()
+	config.StatsdPort = statsdPort
+	config.AdminPort = 9102
+
+	proxyServer := NewServer(config, func() (Config, error) { return config, nil })
+	proxyServer.generation.statsdClient.Open()
+	defer func() { proxyServer.generation.statsdClient.Close() }()
+
+	// writes fail, when refused connection is reported for previous packet
+	for i := 0; i < 3; i++ {
+		sendTestCount(proxyServer, "")
+	}
+
+	recorder := httptest.NewRecorder()
+	proxyServer.adminServer.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Regexp(`statsd_send_errors_total\{backend="127\.0\.0\.1:`+strconv.Itoa(statsdPort)+`"\} [1-9]`, recorder.Body.String())
+}
+
+func TestServerKeepsRestartOptionsOnReload(t *testing.T) {
+	require := require.New(t)
+
+	config := DefaultConfig()
+	config.StatsdBackendMode = BackendModeNone
+	config.CORSAllowedOrigins = []string{"https://before.example.com"}
+
+	reloadedConfig := config
+	reloadedConfig.HTTPPort = config.HTTPPort + 1
+	reloadedConfig.CORSAllowedOrigins = []string{"https://after.example.com"}
+
+	proxyServer := NewServer(config, func() (Config, error) { return reloadedConfig, nil })
+
+	for i := 0; i < 2; i++ {
+		proxyServer.Reload()
+
+		// listener is not changed until restart, so running port is kept
+		require.Equal(config.HTTPPort, proxyServer.config.HTTPPort)
+		require.Equal(reloadedConfig.CORSAllowedOrigins, proxyServer.config.CORSAllowedOrigins)
+		require.NotEqual(reloadedConfig.restartOptions(), proxyServer.config.restartOptions())
+	}
+
+	proxyServer.retiredGenerations.Wait()
+}
@@ -0,0 +1,468 @@
+package statsdclient
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CounterAggregate is a sum of counter values over flush interval, corrected by sample rate
+type CounterAggregate struct {
+	Key   string
+	Tags  []Tag
+	Count float64
+	// Rate is a count per second
+	Rate float64
+}
+
+// GaugeAggregate is a last value of the gauge
+type GaugeAggregate struct {
+	Key   string
+	Tags  []Tag
+	Value float64
+}
+
+// PercentileAggregate is an upper bound of values, lower than percentile of all values
+type PercentileAggregate struct {
+	Percentile float64
+	Upper      float64
+}
+
+// TimerAggregate describes values of timer, histogram or distribution over flush interval
+type TimerAggregate struct {
+	Key string
+	// Type is a StatsD type of metric: ms, h or d
+	Type  string
+	Tags  []Tag
+	Count float64
+	Rate  float64
+	Sum   float64
+	Mean  float64
+	Upper float64
+	Lower float64
+	// Percentiles are ordered as configured in aggregator
+	Percentiles []PercentileAggregate
+}
+
+// SetAggregate is a number of unique members of set over flush interval
+type SetAggregate struct {
+	Key   string
+	Tags  []Tag
+	Count int
+}
+
+// Aggregates are metrics, aggregated over flush interval
+type Aggregates struct {
+	Timestamp time.Time
+	Interval  time.Duration
+	Counters  []CounterAggregate
+	Gauges    []GaugeAggregate
+	Timers    []TimerAggregate
+	Sets      []SetAggregate
+}
+
+// AggregateSink receives aggregated metrics on every flush of aggregator
+type AggregateSink interface {
+	Flush(aggregates Aggregates)
+}
+
+type aggregatedCounter struct {
+	key   string
+	tags  []Tag
+	count float64
+}
+
+type aggregatedGauge struct {
+	key   string
+	tags  []Tag
+	value float64
+	// updated marks gauges, updated since last flush
+	updated bool
+}
+
+// aggregatorMaxTimerSamples is a number of values of timer, kept over flush interval to compute percentiles
+const aggregatorMaxTimerSamples = 10000
+
+type aggregatedTimer struct {
+	key        string
+	metricType string
+	tags       []Tag
+	count      float64
+	sum        float64
+	lower      float64
+	upper      float64
+	// values are uniform sample of all values over flush interval, used to compute percentiles
+	values      []float64
+	valuesCount int
+}
+
+type aggregatedSet struct {
+	key     string
+	tags    []Tag
+	members map[string]struct{}
+}
+
+// Aggregator accumulates counters, gauges, timers and sets like StatsD server,
+// and flushes aggregated values to the sink every flush interval.
+// Events, service checks and raw metrics are not aggregated, and passed to the wrapped client
+type Aggregator struct {
+	StatsdClientInterface
+	sink          AggregateSink
+	flushInterval time.Duration
+	percentiles   []float64
+	// new series are dropped, when maxSeries series are aggregated
+	maxSeries       int
+	onDroppedSeries func(key string)
+	// deleteIdleGauges drops gauges, not updated since last flush, like deleteGauges of StatsD
+	deleteIdleGauges bool
+
+	lock          sync.Mutex
+	intervalStart time.Time
+	counters      map[string]*aggregatedCounter
+	gauges        map[string]*aggregatedGauge
+	timers        map[string]*aggregatedTimer
+	sets          map[string]*aggregatedSet
+
+	flushStop chan struct{}
+	flushDone sync.WaitGroup
+}
+
+// NewAggregator creates aggregator, flushing aggregates to the sink every flushInterval,
+// with upper bounds of passed percentiles of timers.
+// Number of aggregated series is limited by maxSeries, 0 to aggregate any number of series.
+// Gauges, not updated over flush interval, are not flushed any more if deleteIdleGauges is set
+func NewAggregator(
+	client StatsdClientInterface,
+	sink AggregateSink,
+	flushInterval time.Duration,
+	percentiles []float64,
+	maxSeries int,
+	deleteIdleGauges bool,
+) *Aggregator {
+	return &Aggregator{
+		StatsdClientInterface: client,
+		sink:                  sink,
+		flushInterval:         flushInterval,
+		percentiles:           percentiles,
+		maxSeries:             maxSeries,
+		deleteIdleGauges:      deleteIdleGauges,
+		intervalStart:         time.Now(),
+		counters:              make(map[string]*aggregatedCounter),
+		gauges:                make(map[string]*aggregatedGauge),
+		timers:                make(map[string]*aggregatedTimer),
+		sets:                  make(map[string]*aggregatedSet),
+	}
+}
+
+// SetDroppedSeriesHandler sets function, called with metric key on every metric dropped by limit of series.
+// Handler is called under the lock, so it must not send metrics to the same aggregator
+func (aggregator *Aggregator) SetDroppedSeriesHandler(handler func(key string)) {
+	aggregator.onDroppedSeries = handler
+}
+
+// acceptsNewSeries checks limit of series, so keys from clients do not exhaust memory.
+// Must be called under the lock
+func (aggregator *Aggregator) acceptsNewSeries(key string) bool {
+	seriesCount := len(aggregator.counters) + len(aggregator.gauges) + len(aggregator.timers) + len(aggregator.sets)
+	if aggregator.maxSeries <= 0 || seriesCount < aggregator.maxSeries {
+		return true
+	}
+
+	if aggregator.onDroppedSeries != nil {
+		aggregator.onDroppedSeries(key)
+	}
+
+	return false
+}
+
+// seriesID identifies metric by key and tags
+func seriesID(key string, tags []Tag) string {
+	if len(tags) == 0 {
+		return key
+	}
+
+	return key + "," + joinTags(tags, "=", ",")
+}
+
+// Open starts flushing of aggregates
+func (aggregator *Aggregator) Open() {
+	aggregator.StatsdClientInterface.Open()
+
+	aggregator.lock.Lock()
+	aggregator.intervalStart = time.Now()
+	aggregator.lock.Unlock()
+
+	aggregator.flushStop = make(chan struct{})
+	aggregator.flushDone.Add(1)
+	go aggregator.flushOnTick(time.NewTicker(aggregator.flushInterval), aggregator.flushStop)
+}
+
+// Close flushes metrics aggregated since last flush, and closes the wrapped client
+func (aggregator *Aggregator) Close() {
+	if aggregator.flushStop != nil {
+		close(aggregator.flushStop)
+		aggregator.flushDone.Wait()
+		aggregator.flushStop = nil
+	}
+
+	aggregator.Flush()
+
+	aggregator.StatsdClientInterface.Close()
+}
+
+func (aggregator *Aggregator) flushOnTick(ticker *time.Ticker, stop chan struct{}) {
+	defer aggregator.flushDone.Done()
+
+	for {
+		select {
+		case <-ticker.C:
+			aggregator.Flush()
+		case <-stop:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// Count adds value to the counter, corrected by sample rate
+func (aggregator *Aggregator) Count(key string, value int, sampleRate float32, tags []Tag) {
+	id := seriesID(key, tags)
+
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+
+	counter, ok := aggregator.counters[id]
+	if !ok {
+		if !aggregator.acceptsNewSeries(key) {
+			return
+		}
+		counter = &aggregatedCounter{key: key, tags: tags}
+		aggregator.counters[id] = counter
+	}
+
+	counter.count += float64(value) / sampleRateOrOne(sampleRate)
+}
+
+// Timing adds value to the timer
+func (aggregator *Aggregator) Timing(key string, time float64, sampleRate float32, tags []Tag) {
+	aggregator.addTimerValue(key, metricTypeTiming, time, sampleRate, tags)
+}
+
+// Histogram adds value to the histogram, aggregated as timer
+func (aggregator *Aggregator) Histogram(key string, value float64, sampleRate float32, tags []Tag) {
+	aggregator.addTimerValue(key, metricTypeHistogram, value, sampleRate, tags)
+}
+
+// Distribution adds value to the distribution, aggregated as timer
+func (aggregator *Aggregator) Distribution(key string, value float64, sampleRate float32, tags []Tag) {
+	aggregator.addTimerValue(key, metricTypeDistribution, value, sampleRate, tags)
+}
+
+func (aggregator *Aggregator) addTimerValue(key string, metricType string, value float64, sampleRate float32, tags []Tag) {
+	id := metricType + ":" + seriesID(key, tags)
+
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+
+	timer, ok := aggregator.timers[id]
+	if !ok {
+		if !aggregator.acceptsNewSeries(key) {
+			return
+		}
+		timer = &aggregatedTimer{key: key, metricType: metricType, tags: tags, lower: value, upper: value}
+		aggregator.timers[id] = timer
+	}
+
+	timer.count += 1 / sampleRateOrOne(sampleRate)
+	timer.sum += value
+	timer.lower = math.Min(timer.lower, value)
+	timer.upper = math.Max(timer.upper, value)
+
+	// reservoir sampling keeps every value with the same probability
+	timer.valuesCount++
+	if len(timer.values) < aggregatorMaxTimerSamples {
+		timer.values = append(timer.values, value)
+	} else if i := rand.Intn(timer.valuesCount); i < aggregatorMaxTimerSamples {
+		timer.values[i] = value
+	}
+}
+
+// Gauge sets value of the gauge. Gauges keep their values between flushes
+func (aggregator *Aggregator) Gauge(key string, value float64, tags []Tag) {
+	aggregator.setGauge(key, tags, func(float64) float64 { return value })
+}
+
+// GaugeShift shifts value of the gauge
+func (aggregator *Aggregator) GaugeShift(key string, value float64, tags []Tag) {
+	aggregator.setGauge(key, tags, func(current float64) float64 { return current + value })
+}
+
+func (aggregator *Aggregator) setGauge(key string, tags []Tag, update func(current float64) float64) {
+	id := seriesID(key, tags)
+
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+
+	gauge, ok := aggregator.gauges[id]
+	if !ok {
+		if !aggregator.acceptsNewSeries(key) {
+			return
+		}
+		gauge = &aggregatedGauge{key: key, tags: tags}
+		aggregator.gauges[id] = gauge
+	}
+
+	gauge.value = update(gauge.value)
+	gauge.updated = true
+}
+
+// Set adds member to the set
+func (aggregator *Aggregator) Set(key string, value string, tags []Tag) {
+	id := seriesID(key, tags)
+
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+
+	set, ok := aggregator.sets[id]
+	if !ok {
+		if !aggregator.acceptsNewSeries(key) {
+			return
+		}
+		set = &aggregatedSet{key: key, tags: tags, members: make(map[string]struct{})}
+		aggregator.sets[id] = set
+	}
+
+	set.members[value] = struct{}{}
+}
+
+func sampleRateOrOne(sampleRate float32) float64 {
+	if sampleRate <= 0 || sampleRate > 1 {
+		return 1
+	}
+
+	return float64(sampleRate)
+}
+
+// Flush aggregates metrics accumulated since last flush, and passes them to the sink
+func (aggregator *Aggregator) Flush() {
+	aggregator.lock.Lock()
+
+	now := time.Now()
+	interval := now.Sub(aggregator.intervalStart)
+	aggregator.intervalStart = now
+
+	counters, timers, sets := aggregator.counters, aggregator.timers, aggregator.sets
+	aggregator.counters = make(map[string]*aggregatedCounter)
+	aggregator.timers = make(map[string]*aggregatedTimer)
+	aggregator.sets = make(map[string]*aggregatedSet)
+
+	gauges := make([]GaugeAggregate, 0, len(aggregator.gauges))
+	for id, gauge := range aggregator.gauges {
+		if !gauge.updated && aggregator.deleteIdleGauges {
+			delete(aggregator.gauges, id)
+			continue
+		}
+
+		gauges = append(gauges, GaugeAggregate{Key: gauge.key, Tags: gauge.tags, Value: gauge.value})
+		gauge.updated = false
+	}
+
+	aggregator.lock.Unlock()
+
+	if len(counters) == 0 && len(timers) == 0 && len(sets) == 0 && len(gauges) == 0 {
+		return
+	}
+
+	intervalSeconds := interval.Seconds()
+	if intervalSeconds <= 0 {
+		intervalSeconds = aggregator.flushInterval.Seconds()
+	}
+
+	aggregates := Aggregates{
+		Timestamp: now,
+		Interval:  interval,
+		Counters:  make([]CounterAggregate, 0, len(counters)),
+		Gauges:    gauges,
+		Timers:    make([]TimerAggregate, 0, len(timers)),
+		Sets:      make([]SetAggregate, 0, len(sets)),
+	}
+
+	for _, counter := range counters {
+		aggregates.Counters = append(aggregates.Counters, CounterAggregate{
+			Key:   counter.key,
+			Tags:  counter.tags,
+			Count: counter.count,
+			Rate:  counter.count / intervalSeconds,
+		})
+	}
+
+	for _, timer := range timers {
+		aggregates.Timers = append(aggregates.Timers, aggregateTimer(timer, intervalSeconds, aggregator.percentiles))
+	}
+
+	for _, set := range sets {
+		aggregates.Sets = append(aggregates.Sets, SetAggregate{Key: set.key, Tags: set.tags, Count: len(set.members)})
+	}
+
+	aggregates.sort()
+
+	aggregator.sink.Flush(aggregates)
+}
+
+// aggregateTimer computes derived values of the timer
+func aggregateTimer(timer *aggregatedTimer, intervalSeconds float64, percentiles []float64) TimerAggregate {
+	values := timer.values
+	sort.Float64s(values)
+
+	aggregate := TimerAggregate{
+		Key:         timer.key,
+		Type:        timer.metricType,
+		Tags:        timer.tags,
+		Count:       timer.count,
+		Rate:        timer.count / intervalSeconds,
+		Sum:         timer.sum,
+		Mean:        timer.sum / float64(timer.valuesCount),
+		Upper:       timer.upper,
+		Lower:       timer.lower,
+		Percentiles: make([]PercentileAggregate, len(percentiles)),
+	}
+
+	// nearest rank of percentile
+	for i, percentile := range percentiles {
+		rank := int(math.Ceil(percentile / 100 * float64(len(values))))
+		if rank < 1 {
+			rank = 1
+		}
+		if rank > len(values) {
+			rank = len(values)
+		}
+
+		aggregate.Percentiles[i] = PercentileAggregate{Percentile: percentile, Upper: values[rank-1]}
+	}
+
+	return aggregate
+}
+
+// sort orders aggregates by key, so they are flushed in stable order
+func (aggregates *Aggregates) sort() {
+	sort.Slice(aggregates.Counters, func(i, j int) bool {
+		return seriesID(aggregates.Counters[i].Key, aggregates.Counters[i].Tags) < seriesID(aggregates.Counters[j].Key, aggregates.Counters[j].Tags)
+	})
+	sort.Slice(aggregates.Gauges, func(i, j int) bool {
+		return seriesID(aggregates.Gauges[i].Key, aggregates.Gauges[i].Tags) < seriesID(aggregates.Gauges[j].Key, aggregates.Gauges[j].Tags)
+	})
+	sort.Slice(aggregates.Timers, func(i, j int) bool {
+		return aggregates.Timers[i].Type+":"+seriesID(aggregates.Timers[i].Key, aggregates.Timers[i].Tags) < aggregates.Timers[j].Type+":"+seriesID(aggregates.Timers[j].Key, aggregates.Timers[j].Tags)
+	})
+	sort.Slice(aggregates.Sets, func(i, j int) bool {
+		return seriesID(aggregates.Sets[i].Key, aggregates.Sets[i].Tags) < seriesID(aggregates.Sets[j].Key, aggregates.Sets[j].Tags)
+	})
+}
+
+// percentileSuffix names percentile like StatsD does, "90" or "99_9"
+func percentileSuffix(percentile float64) string {
+	return strings.Replace(formatFloat(percentile), ".", "_", -1)
+}
@@ -0,0 +1,167 @@
+package statsdclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink keeps flushed aggregates
+type recordingSink struct {
+	flushes []Aggregates
+}
+
+func (sink *recordingSink) Flush(aggregates Aggregates) {
+	sink.flushes = append(sink.flushes, aggregates)
+}
+
+func TestAggregatorAggregatesMetrics(t *testing.T) {
+	require := require.New(t)
+
+	sink := &recordingSink{}
+	aggregator := NewAggregator(NewClient("127.0.0.1", 8125, TagFormatInflux), sink, time.Second, []float64{50, 90}, 0, false)
+
+	aggregator.Count("clicks", 1, 1, nil)
+	aggregator.Count("clicks", 2, 1, nil)
+	aggregator.Count("clicks", 1, 0.5, []Tag{{"env", "prod"}})
+
+	for value := 1; value <= 10; value++ {
+		aggregator.Timing("render", float64(value), 1, nil)
+	}
+
+	aggregator.Gauge("memory", 512, nil)
+	aggregator.GaugeShift("memory", -12, nil)
+
+	aggregator.Set("visitors", "a", nil)
+	aggregator.Set("visitors", "b", nil)
+	aggregator.Set("visitors", "a", nil)
+
+	aggregator.Flush()
+	require.Len(sink.flushes, 1)
+	aggregates := sink.flushes[0]
+
+	require.Len(aggregates.Counters, 2)
+	require.Equal("clicks", aggregates.Counters[0].Key)
+	require.Equal(3.0, aggregates.Counters[0].Count)
+	require.Equal([]Tag{{"env", "prod"}}, aggregates.Counters[1].Tags)
+	require.Equal(2.0, aggregates.Counters[1].Count)
+
+	require.Equal([]GaugeAggregate{{Key: "memory", Value: 500}}, aggregates.Gauges)
+
+	require.Len(aggregates.Timers, 1)
+	timer := aggregates.Timers[0]
+	require.Equal("render", timer.Key)
+	require.Equal("ms", timer.Type)
+	require.Equal(10.0, timer.Count)
+	require.Equal(55.0, timer.Sum)
+	require.Equal(5.5, timer.Mean)
+	require.Equal(10.0, timer.Upper)
+	require.Equal(1.0, timer.Lower)
+	require.Equal([]PercentileAggregate{{50, 5}, {90, 9}}, timer.Percentiles)
+
+	require.Equal([]SetAggregate{{Key: "visitors", Count: 2}}, aggregates.Sets)
+
+	// counters, timers and sets are reset on flush, gauges keep their values
+	aggregator.GaugeShift("memory", 10, nil)
+	aggregator.Flush()
+	require.Len(sink.flushes, 2)
+	require.Empty(sink.flushes[1].Counters)
+	require.Empty(sink.flushes[1].Timers)
+	require.Empty(sink.flushes[1].Sets)
+	require.Equal([]GaugeAggregate{{Key: "memory", Value: 510}}, sink.flushes[1].Gauges)
+}
+
+func TestAggregatorFlushesOnInterval(t *testing.T) {
+	server, port := newTestServer(t)
+
+	client := NewClient("127.0.0.1", port, TagFormatInflux)
+	aggregator := NewAggregator(client, NewStatsdSink(client), 50*time.Millisecond, nil, 0, false)
+	aggregator.Open()
+	defer aggregator.Close()
+
+	require := require.New(t)
+
+	aggregator.Count("clicks", 1, 1, nil)
+	aggregator.Count("clicks", 1, 1, nil)
+	require.Equal("clicks:2|c", readPacket(t, server))
+
+	// not aggregated metrics are passed to the client
+	aggregator.Raw("visitors:42|s")
+	require.Equal("visitors:42|s", readPacket(t, server))
+}
+
+func TestAggregatorLimitsSeries(t *testing.T) {
+	require := require.New(t)
+
+	sink := &recordingSink{}
+	aggregator := NewAggregator(NopClient{}, sink, time.Second, nil, 2, false)
+
+	var droppedKeys []string
+	aggregator.SetDroppedSeriesHandler(func(key string) {
+		droppedKeys = append(droppedKeys, key)
+	})
+
+	aggregator.Count("clicks", 1, 1, nil)
+	aggregator.Gauge("memory", 512, nil)
+	aggregator.Count("clicks", 1, 1, []Tag{{"user", "1"}})
+	aggregator.Timing("render", 10, 1, nil)
+	aggregator.Set("visitors", "a", nil)
+
+	// existing series are still updated
+	aggregator.Count("clicks", 1, 1, nil)
+
+	aggregator.Flush()
+	require.Len(sink.flushes[0].Counters, 1)
+	require.Equal("clicks", sink.flushes[0].Counters[0].Key)
+	require.Equal(2.0, sink.flushes[0].Counters[0].Count)
+	require.Equal([]GaugeAggregate{{Key: "memory", Value: 512}}, sink.flushes[0].Gauges)
+	require.Empty(sink.flushes[0].Timers)
+	require.Empty(sink.flushes[0].Sets)
+	require.Equal([]string{"clicks", "render", "visitors"}, droppedKeys)
+}
+
+func TestAggregatorDeletesIdleGauges(t *testing.T) {
+	require := require.New(t)
+
+	sink := &recordingSink{}
+	aggregator := NewAggregator(NopClient{}, sink, time.Second, nil, 0, true)
+
+	aggregator.Gauge("memory", 512, nil)
+	aggregator.Gauge("cpu", 50, nil)
+	aggregator.Flush()
+
+	aggregator.Gauge("cpu", 60, nil)
+	aggregator.Flush()
+
+	// idle gauges are deleted, so they are not flushed any more
+	aggregator.Flush()
+
+	require.Len(sink.flushes, 2)
+	require.Equal([]GaugeAggregate{{Key: "cpu", Value: 50}, {Key: "memory", Value: 512}}, sink.flushes[0].Gauges)
+	require.Equal([]GaugeAggregate{{Key: "cpu", Value: 60}}, sink.flushes[1].Gauges)
+}
+
+func TestAggregatorLimitsTimerSamples(t *testing.T) {
+	require := require.New(t)
+
+	sink := &recordingSink{}
+	aggregator := NewAggregator(NopClient{}, sink, time.Second, []float64{50}, 0, false)
+
+	count := 3 * aggregatorMaxTimerSamples
+	for value := 1; value <= count; value++ {
+		aggregator.Timing("render", float64(value), 1, nil)
+	}
+
+	require.Len(aggregator.timers["ms:render"].values, aggregatorMaxTimerSamples)
+
+	aggregator.Flush()
+	timer := sink.flushes[0].Timers[0]
+
+	// count, sum and bounds are exact, percentiles are estimated by sample
+	require.Equal(float64(count), timer.Count)
+	require.Equal(float64(count*(count+1)/2), timer.Sum)
+	require.Equal(1.0, timer.Lower)
+	require.Equal(float64(count), timer.Upper)
+	require.InDelta(float64(count)/2, timer.Percentiles[0].Upper, float64(count)/10)
+}
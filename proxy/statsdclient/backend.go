@@ -0,0 +1,84 @@
+package statsdclient
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// Backend describes StatsD server, receiving metrics
+type Backend struct {
+	// Network is a transport of metrics: udp, tcp or unixgram
+	Network string
+	Host    string
+	Port    int
+	// Path is a path of Unix domain socket
+	Path      string
+	TagFormat TagFormat
+	// HealthPort is a TCP port of StatsD admin interface, used to check health of the backend, 0 if not checked
+	HealthPort int
+}
+
+// Address returns "host:port" of the backend, or path of the socket
+func (backend Backend) Address() string {
+	if backend.Network == TransportUnixgram {
+		return backend.Path
+	}
+
+	return net.JoinHostPort(backend.Host, strconv.Itoa(backend.Port))
+}
+
+// ParseBackend parses backend from URL "udp://host:port?tag-format=dogstatsd&health-port=8126",
+// "tcp://host:port" or "unixgram:///path/to/socket".
+// If tag format is not specified in URL, defaultTagFormat is used
+func ParseBackend(backendURL string, defaultTagFormat TagFormat) (Backend, error) {
+	parsedURL, err := url.Parse(backendURL)
+	if err != nil {
+		return Backend{}, fmt.Errorf("Invalid backend URL %q: %v", backendURL, err)
+	}
+
+	backend := Backend{Network: parsedURL.Scheme}
+
+	switch parsedURL.Scheme {
+	case TransportUDP, TransportTCP:
+		host, portString, err := net.SplitHostPort(parsedURL.Host)
+		if err != nil {
+			return Backend{}, fmt.Errorf("Invalid address of backend URL %q: %v", backendURL, err)
+		}
+
+		port, err := strconv.Atoi(portString)
+		if err != nil || port <= 0 || port > 65535 {
+			return Backend{}, fmt.Errorf("Invalid port of backend URL %q", backendURL)
+		}
+
+		backend.Host = host
+		backend.Port = port
+	case TransportUnixgram:
+		if parsedURL.Host != "" || parsedURL.Path == "" {
+			return Backend{}, fmt.Errorf("Invalid socket path of backend URL %q", backendURL)
+		}
+
+		backend.Path = parsedURL.Path
+	default:
+		return Backend{}, fmt.Errorf("Unsupported scheme of backend URL %q", backendURL)
+	}
+
+	backend.TagFormat = defaultTagFormat
+	if tagFormatName := parsedURL.Query().Get("tag-format"); tagFormatName != "" {
+		if backend.TagFormat, err = ParseTagFormat(tagFormatName); err != nil {
+			return Backend{}, err
+		}
+	}
+
+	if healthPortString := parsedURL.Query().Get("health-port"); healthPortString != "" {
+		healthPort, err := strconv.Atoi(healthPortString)
+		if err != nil || healthPort <= 0 || healthPort > 65535 || backend.Host == "" {
+			return Backend{}, fmt.Errorf("Invalid health port of backend URL %q", backendURL)
+		}
+
+		backend.HealthPort = healthPort
+	}
+
+	return backend, nil
+}
@@ -0,0 +1,49 @@
+package statsdclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBackend(t *testing.T) {
+	require := require.New(t)
+
+	backend, err := ParseBackend("udp://statsd.local:8125", TagFormatInflux)
+	require.NoError(err)
+	require.Equal(Backend{Network: "udp", Host: "statsd.local", Port: 8125, TagFormat: TagFormatInflux}, backend)
+
+	backend, err = ParseBackend("udp://[::1]:8126?tag-format=dogstatsd", TagFormatInflux)
+	require.NoError(err)
+	require.Equal(Backend{Network: "udp", Host: "::1", Port: 8126, TagFormat: TagFormatDogStatsD}, backend)
+	require.Equal("[::1]:8126", backend.Address())
+
+	backend, err = ParseBackend("udp://statsd.local:8125?health-port=8126", TagFormatInflux)
+	require.NoError(err)
+	require.Equal(Backend{Network: "udp", Host: "statsd.local", Port: 8125, TagFormat: TagFormatInflux, HealthPort: 8126}, backend)
+
+	backend, err = ParseBackend("tcp://aggregator.local:8125", TagFormatInflux)
+	require.NoError(err)
+	require.Equal(Backend{Network: "tcp", Host: "aggregator.local", Port: 8125, TagFormat: TagFormatInflux}, backend)
+
+	backend, err = ParseBackend("unixgram:///var/run/datadog/dsd.socket?tag-format=dogstatsd", TagFormatInflux)
+	require.NoError(err)
+	require.Equal(Backend{Network: "unixgram", Path: "/var/run/datadog/dsd.socket", TagFormat: TagFormatDogStatsD}, backend)
+	require.Equal("/var/run/datadog/dsd.socket", backend.Address())
+
+	for _, backendURL := range []string{
+		"statsd.local:8125",
+		"http://statsd.local:8125",
+		"udp://statsd.local",
+		"udp://statsd.local:port",
+		"udp://statsd.local:70000",
+		"udp://statsd.local:8125?tag-format=unknown",
+		"udp://statsd.local:8125?health-port=admin",
+		"tcp://aggregator.local",
+		"unixgram://dsd.socket",
+		"unixgram:///var/run/datadog/dsd.socket?health-port=8126",
+	} {
+		_, err := ParseBackend(backendURL, TagFormatInflux)
+		require.Error(err, backendURL)
+	}
+}
@@ -0,0 +1,386 @@
+package statsdclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const metricTypeCount = "c"
+const metricTypeGauge = "g"
+const metricTypeTiming = "ms"
+const metricTypeSet = "s"
+const metricTypeHistogram = "h"
+const metricTypeDistribution = "d"
+
+// DefaultMaxPacketSize fits packet into Ethernet MTU of 1500 bytes with IP and UDP headers and options
+const DefaultMaxPacketSize = 1432
+
+// Transports of metrics to StatsD server
+const (
+	// TransportUDP sends every packet as UDP datagram
+	TransportUDP = "udp"
+	// TransportTCP sends metrics by TCP connection, terminating every packet with a line break
+	TransportTCP = "tcp"
+	// TransportUnixgram sends every packet as datagram to Unix domain socket, like "dsd.socket" of Datadog agent
+	TransportUnixgram = "unixgram"
+)
+
+// dialTimeout limits time of connecting to StatsD server
+const dialTimeout = time.Second
+
+// errConnectionNotOpened is reported when metric is dropped because StatsD server is not connected
+var errConnectionNotOpened = errors.New("StatsD connection not opened")
+
+// reconnectInterval limits how often broken connection to StatsD server is dialed again
+const reconnectInterval = time.Second
+
+// Client sends metrics to StatsD server by UDP, TCP or Unix datagram socket
+type Client struct {
+	network       string
+	address       string
+	tagFormat     TagFormat
+	conn          net.Conn
+	connLock      sync.Mutex
+	lastDialTime  time.Time
+	flushInterval time.Duration
+	maxPacketSize int
+	buffer        []byte
+	bufferLock    sync.Mutex
+	flushTicker   *time.Ticker
+	flushStop     chan struct{}
+	onSendError   func(err error)
+}
+
+// NewClient creates new StatsD client, sending metrics by UDP
+func NewClient(
+	statsdHost string,
+	statsdPort int,
+	tagFormat TagFormat,
+) *Client {
+	return &Client{
+		network:   TransportUDP,
+		address:   net.JoinHostPort(statsdHost, strconv.Itoa(statsdPort)),
+		tagFormat: tagFormat,
+	}
+}
+
+// NewBufferedClient creates new StatsD client, which packs metrics into packets up to maxPacketSize bytes
+// and sends them by UDP every flushInterval, or earlier when packet is full
+func NewBufferedClient(
+	statsdHost string,
+	statsdPort int,
+	tagFormat TagFormat,
+	flushInterval time.Duration,
+	maxPacketSize int,
+) *Client {
+	return newBufferedClient(
+		TransportUDP,
+		net.JoinHostPort(statsdHost, strconv.Itoa(statsdPort)),
+		tagFormat,
+		flushInterval,
+		maxPacketSize,
+	)
+}
+
+// NewTransportClient creates new StatsD client, sending metrics to address by one of transports.
+// Address is "host:port" for UDP and TCP, and path of socket for Unix datagram transport.
+// Metrics are buffered if flushInterval is set
+func NewTransportClient(
+	network string,
+	address string,
+	tagFormat TagFormat,
+	flushInterval time.Duration,
+	maxPacketSize int,
+) *Client {
+	if flushInterval > 0 {
+		return newBufferedClient(network, address, tagFormat, flushInterval, maxPacketSize)
+	}
+
+	return &Client{
+		network:   network,
+		address:   address,
+		tagFormat: tagFormat,
+	}
+}
+
+func newBufferedClient(
+	network string,
+	address string,
+	tagFormat TagFormat,
+	flushInterval time.Duration,
+	maxPacketSize int,
+) *Client {
+	if maxPacketSize <= 0 {
+		maxPacketSize = DefaultMaxPacketSize
+	}
+
+	return &Client{
+		network:       network,
+		address:       address,
+		tagFormat:     tagFormat,
+		flushInterval: flushInterval,
+		maxPacketSize: maxPacketSize,
+		buffer:        make([]byte, 0, maxPacketSize),
+	}
+}
+
+// Open connection to StatsD server.
+// If server is not available, connection is dialed again on sending of metrics
+func (client *Client) Open() {
+	client.connLock.Lock()
+	client.dial()
+	client.connLock.Unlock()
+
+	// start flushing of buffer
+	if client.buffer != nil && client.flushInterval > 0 {
+		client.flushTicker = time.NewTicker(client.flushInterval)
+		client.flushStop = make(chan struct{})
+
+		go client.flushOnTick(client.flushTicker, client.flushStop)
+	}
+}
+
+// Close flushes buffered metrics and closes connection to StatsD server
+func (client *Client) Close() {
+	if client.flushTicker != nil {
+		client.flushTicker.Stop()
+		close(client.flushStop)
+		client.flushTicker = nil
+	}
+
+	client.Flush()
+
+	client.connLock.Lock()
+	defer client.connLock.Unlock()
+
+	if client.conn == nil {
+		return
+	}
+
+	client.conn.Close()
+	client.conn = nil
+}
+
+// SetSendErrorHandler sets function, called on every packet which failed to send.
+// Handler is called under the lock of connection, so it must not send metrics by the same client
+func (client *Client) SetSendErrorHandler(handler func(err error)) {
+	client.onSendError = handler
+}
+
+// dial opens connection to StatsD server, and must be called under the connLock
+func (client *Client) dial() {
+	client.lastDialTime = time.Now()
+
+	conn, err := net.DialTimeout(client.network, client.address, dialTimeout)
+	if err != nil {
+		log.WithFields(log.Fields{"Network": client.network, "Address": client.address, "Error": err}).Error("Cannot open StatsD connection")
+		return
+	}
+
+	client.conn = conn
+}
+
+func (client *Client) flushOnTick(ticker *time.Ticker, stop chan struct{}) {
+	for {
+		select {
+		case <-ticker.C:
+			client.Flush()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Flush sends buffered metrics to StatsD server
+func (client *Client) Flush() {
+	client.bufferLock.Lock()
+	defer client.bufferLock.Unlock()
+
+	client.flushBuffer()
+}
+
+// flushBuffer sends buffered metrics, and must be called under the bufferLock
+func (client *Client) flushBuffer() {
+	if len(client.buffer) == 0 {
+		return
+	}
+
+	client.sendPacket(client.buffer)
+	client.buffer = client.buffer[:0]
+}
+
+// Count tracks counter with sampling
+func (client *Client) Count(key string, value int, sampleRate float32, tags []Tag) {
+	client.sendSampled(key, strconv.Itoa(value)+"|"+metricTypeCount, sampleRate, tags)
+}
+
+// Timing tracks time in milliseconds with sampling
+func (client *Client) Timing(key string, time float64, sampleRate float32, tags []Tag) {
+	client.sendSampled(key, formatFloat(time)+"|"+metricTypeTiming, sampleRate, tags)
+}
+
+// Gauge sets gauge value.
+// Negative value interprets by StatsD as negative shift, so gauge is reset to 0 before it in the same packet.
+func (client *Client) Gauge(key string, value float64, tags []Tag) {
+	metric := client.tagFormat.encode(key, formatFloat(value)+"|"+metricTypeGauge, tags)
+	if value < 0 {
+		metric = client.tagFormat.encode(key, "0|"+metricTypeGauge, tags) + "\n" + metric
+	}
+
+	client.write(metric)
+}
+
+// GaugeShift decreases previously set value if negative value passed, and increases if positive
+func (client *Client) GaugeShift(key string, value float64, tags []Tag) {
+	shift := formatFloat(value)
+	if value >= 0 {
+		shift = "+" + shift
+	}
+
+	client.send(key, shift+"|"+metricTypeGauge, tags)
+}
+
+// Set adds member to the set
+func (client *Client) Set(key string, value string, tags []Tag) {
+	client.send(key, value+"|"+metricTypeSet, tags)
+}
+
+// Histogram tracks value for percentile aggregation on StatsD server with sampling
+func (client *Client) Histogram(key string, value float64, sampleRate float32, tags []Tag) {
+	client.sendSampled(key, formatFloat(value)+"|"+metricTypeHistogram, sampleRate, tags)
+}
+
+// Distribution tracks value for global percentile aggregation with sampling
+func (client *Client) Distribution(key string, value float64, sampleRate float32, tags []Tag) {
+	client.sendSampled(key, formatFloat(value)+"|"+metricTypeDistribution, sampleRate, tags)
+}
+
+// Event sends DogStatsD event
+func (client *Client) Event(event Event) {
+	client.write(event.datagram())
+}
+
+// ServiceCheck sends DogStatsD service check
+func (client *Client) ServiceCheck(check ServiceCheck) {
+	client.write(check.datagram())
+}
+
+// Raw sends metric already encoded in StatsD line format
+func (client *Client) Raw(metric string) {
+	client.write(metric)
+}
+
+// formatFloat formats value without exponent and without trailing zeros,
+// so integer values are sent as integers
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// sendSampled marks metric with the sample rate.
+// Metrics are skipped according to sample rate by SamplingClient before reaching the client
+func (client *Client) sendSampled(key string, metricValue string, sampleRate float32, tags []Tag) {
+	if sampleRate < 1 {
+		metricValue = fmt.Sprintf("%s|@%g", metricValue, sampleRate)
+	}
+
+	client.send(key, metricValue, tags)
+}
+
+// send encodes tags of the metric in configured format
+func (client *Client) send(key string, metricValue string, tags []Tag) {
+	client.write(client.tagFormat.encode(key, metricValue, tags))
+}
+
+// write sends metric immediately, or adds it to the buffer if client is buffered
+func (client *Client) write(metric string) {
+	if client.buffer == nil {
+		client.sendPacket([]byte(metric))
+		return
+	}
+
+	client.bufferLock.Lock()
+	defer client.bufferLock.Unlock()
+
+	// send buffered metrics if new one does not fit into the packet
+	if len(client.buffer) > 0 && len(client.buffer)+1+len(metric) > client.maxPacketSize {
+		client.flushBuffer()
+	}
+
+	// metric bigger than packet is sent as is
+	if len(metric) > client.maxPacketSize {
+		client.sendPacket([]byte(metric))
+		return
+	}
+
+	if len(client.buffer) > 0 {
+		client.buffer = append(client.buffer, '\n')
+	}
+	client.buffer = append(client.buffer, metric...)
+}
+
+func (client *Client) sendPacket(packet []byte) {
+	// metrics in TCP stream are separated by line breaks
+	if client.network == TransportTCP {
+		packet = append(packet[:len(packet):len(packet)], '\n')
+	}
+
+	client.connLock.Lock()
+	defer client.connLock.Unlock()
+
+	// dial broken connection again, but not on every metric while server is down
+	if client.conn == nil && time.Since(client.lastDialTime) >= reconnectInterval {
+		client.dial()
+	}
+
+	if client.conn == nil {
+		log.WithFields(log.Fields{"Metric": string(packet)}).Debug("StatsD connection not opened")
+		client.reportSendError(errConnectionNotOpened)
+		return
+	}
+
+	_, err := client.conn.Write(packet)
+	if err == nil {
+		return
+	}
+
+	// UDP socket is not broken by errors of unreachable server, so next metrics are sent by it
+	// as soon as server is up again
+	if client.network == TransportUDP {
+		log.WithFields(log.Fields{"Error": err}).Error("Cannot send metric to StatsD")
+		client.reportSendError(err)
+		return
+	}
+
+	// connection is broken, so it is dialed again
+	client.conn.Close()
+	client.conn = nil
+
+	// metrics are sent by TCP once again to the new connection, so they are not lost on reconnect
+	if client.network == TransportTCP {
+		client.dial()
+		if client.conn != nil {
+			_, err = client.conn.Write(packet)
+			if err == nil {
+				return
+			}
+
+			client.conn.Close()
+			client.conn = nil
+		}
+	}
+
+	log.WithFields(log.Fields{"Error": err}).Error("Cannot send metric to StatsD")
+	client.reportSendError(err)
+}
+
+func (client *Client) reportSendError(err error) {
+	if client.onSendError != nil {
+		client.onSendError(err)
+	}
+}
@@ -0,0 +1,173 @@
+package statsdclient
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer starts UDP server on random local port
+func newTestServer(t *testing.T) (*net.UDPConn, int) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// readPacket reads next packet received by test server
+func readPacket(t *testing.T, conn *net.UDPConn) string {
+	buffer := make([]byte, 65536)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	length, err := conn.Read(buffer)
+	require.NoError(t, err)
+
+	return string(buffer[:length])
+}
+
+func TestNewClient(t *testing.T) {
+	client := NewClient("127.0.0.1", 8125, TagFormatInflux)
+
+	require.Equal(t, "*statsdclient.Client", reflect.TypeOf(client).String())
+}
+
+func TestClientSendsMetrics(t *testing.T) {
+	server, port := newTestServer(t)
+
+	client := NewClient("127.0.0.1", port, TagFormatDogStatsD)
+	client.Open()
+	defer client.Close()
+
+	require := require.New(t)
+
+	client.Count("clicks", 3, 1, nil)
+	require.Equal("clicks:3|c", readPacket(t, server))
+
+	client.Timing("render", 120, 1, nil)
+	require.Equal("render:120|ms", readPacket(t, server))
+
+	client.Gauge("memory", 512, nil)
+	require.Equal("memory:512|g", readPacket(t, server))
+
+	client.Gauge("temperature", -5.5, []Tag{{"room", "1"}})
+	require.Equal("temperature:0|g|#room:1\ntemperature:-5.5|g|#room:1", readPacket(t, server))
+
+	client.GaugeShift("memory", -12, nil)
+	require.Equal("memory:-12|g", readPacket(t, server))
+
+	client.GaugeShift("memory", 12, nil)
+	require.Equal("memory:+12|g", readPacket(t, server))
+
+	client.Gauge("cls", 0.0125, nil)
+	require.Equal("cls:0.0125|g", readPacket(t, server))
+
+	client.Timing("fid", 0.35, 1, nil)
+	require.Equal("fid:0.35|ms", readPacket(t, server))
+
+	client.Set("visitors", "f81d4fae-7dec-11d0-a765-00a0c91e6bf6", nil)
+	require.Equal("visitors:f81d4fae-7dec-11d0-a765-00a0c91e6bf6|s", readPacket(t, server))
+
+	client.Histogram("latency", 12.5, 1, []Tag{{"env", "prod"}})
+	require.Equal("latency:12.5|h|#env:prod", readPacket(t, server))
+
+	client.Distribution("latency", 13, 1, nil)
+	require.Equal("latency:13|d", readPacket(t, server))
+
+	client.Raw("some.key:1|c|@0.1|#env:prod")
+	require.Equal("some.key:1|c|@0.1|#env:prod", readPacket(t, server))
+}
+
+func TestClientMarksSampledMetrics(t *testing.T) {
+	server, port := newTestServer(t)
+
+	client := NewClient("127.0.0.1", port, TagFormatInflux)
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 0.25, []Tag{{"env", "prod"}})
+
+	require.Equal(t, "clicks,env=prod:1|c|@0.25", readPacket(t, server))
+}
+
+func TestBufferedClientPacksMetrics(t *testing.T) {
+	server, port := newTestServer(t)
+
+	client := NewBufferedClient("127.0.0.1", port, TagFormatInflux, 0, 30)
+	client.Open()
+
+	require := require.New(t)
+
+	client.Count("clicks", 1, 1, nil)
+	client.Count("views", 2, 1, nil)
+	client.Gauge("temperature", -5, nil)
+	client.Raw("some.very.long.metric.key.name:1|c")
+	client.Set("visitors", "42", nil)
+
+	require.Equal("clicks:1|c\nviews:2|c", readPacket(t, server))
+	require.Equal("temperature:0|g\ntemperature:-5|g", readPacket(t, server))
+	require.Equal("some.very.long.metric.key.name:1|c", readPacket(t, server))
+
+	// rest of buffer sent on close
+	client.Close()
+	require.Equal("visitors:42|s", readPacket(t, server))
+}
+
+func TestBufferedClientFlushesOnInterval(t *testing.T) {
+	server, port := newTestServer(t)
+
+	client := NewBufferedClient("127.0.0.1", port, TagFormatInflux, 50*time.Millisecond, 0)
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 1, nil)
+	client.Count("views", 2, 1, nil)
+
+	require.Equal(t, "clicks:1|c\nviews:2|c", readPacket(t, server))
+}
+
+func TestUDPClientSendsMetricsAfterServerRestart(t *testing.T) {
+	require := require.New(t)
+
+	server, port := newTestServer(t)
+
+	client := NewClient("127.0.0.1", port, TagFormatInflux)
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 1, nil)
+	require.Equal("clicks:1|c", readPacket(t, server))
+
+	// writes fail with refused connection while server is down
+	server.Close()
+	client.Count("clicks", 2, 1, nil)
+	client.Count("clicks", 3, 1, nil)
+
+	restartedServer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	require.NoError(err)
+	defer restartedServer.Close()
+
+	client.Count("clicks", 4, 1, nil)
+	require.Equal("clicks:4|c", readPacket(t, restartedServer))
+}
+
+func TestClientReportsSendErrors(t *testing.T) {
+	require := require.New(t)
+
+	client := NewTransportClient(TransportUnixgram, t.TempDir()+"/missing.socket", TagFormatInflux, 0, 0)
+
+	var sendErrors []error
+	client.SetSendErrorHandler(func(err error) {
+		sendErrors = append(sendErrors, err)
+	})
+
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 1, nil)
+	require.Equal([]error{errConnectionNotOpened}, sendErrors)
+}
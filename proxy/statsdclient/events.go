@@ -0,0 +1,182 @@
+package statsdclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes invalid or missing field of event or service check
+type FieldError struct {
+	Field   string
+	Missing bool
+	Message string
+}
+
+func (err *FieldError) Error() string {
+	return err.Message
+}
+
+// newFieldError creates error of the field with the formatted message
+func newFieldError(field string, missing bool, format string, args ...interface{}) *FieldError {
+	return &FieldError{Field: field, Missing: missing, Message: fmt.Sprintf(format, args...)}
+}
+
+// Event is a DogStatsD event, shown on dashboards and in the event stream
+type Event struct {
+	Title          string
+	Text           string
+	Timestamp      int64
+	Hostname       string
+	AggregationKey string
+	Priority       string
+	SourceType     string
+	AlertType      string
+	Tags           []Tag
+}
+
+var eventPriorities = map[string]bool{"normal": true, "low": true}
+
+var eventAlertTypes = map[string]bool{"error": true, "warning": true, "info": true, "success": true}
+
+// Validate checks that event may be encoded to DogStatsD datagram
+func (event Event) Validate() error {
+	if event.Title == "" {
+		return newFieldError("title", true, "Event title not specified")
+	}
+
+	if event.Priority != "" && !eventPriorities[event.Priority] {
+		return newFieldError("priority", false, "Invalid event priority %q", event.Priority)
+	}
+
+	if event.AlertType != "" && !eventAlertTypes[event.AlertType] {
+		return newFieldError("alertType", false, "Invalid event alert type %q", event.AlertType)
+	}
+
+	return validateDatagramFields(map[string]string{
+		"hostname":       event.Hostname,
+		"aggregationKey": event.AggregationKey,
+		"sourceType":     event.SourceType,
+	}, event.Tags)
+}
+
+// datagram encodes event as "_e{title.length,text.length}:title|text|d:timestamp|h:hostname|p:priority|t:alert_type|#tags"
+func (event Event) datagram() string {
+	title := escapeDatagramText(event.Title)
+	text := escapeDatagramText(event.Text)
+
+	var datagram strings.Builder
+	fmt.Fprintf(&datagram, "_e{%d,%d}:%s|%s", len(title), len(text), title, text)
+
+	if event.Timestamp != 0 {
+		datagram.WriteString("|d:" + strconv.FormatInt(event.Timestamp, 10))
+	}
+	if event.Hostname != "" {
+		datagram.WriteString("|h:" + event.Hostname)
+	}
+	if event.AggregationKey != "" {
+		datagram.WriteString("|k:" + event.AggregationKey)
+	}
+	if event.Priority != "" {
+		datagram.WriteString("|p:" + event.Priority)
+	}
+	if event.SourceType != "" {
+		datagram.WriteString("|s:" + event.SourceType)
+	}
+	if event.AlertType != "" {
+		datagram.WriteString("|t:" + event.AlertType)
+	}
+	if len(event.Tags) > 0 {
+		datagram.WriteString("|#" + joinTags(event.Tags, ":", ","))
+	}
+
+	return datagram.String()
+}
+
+// ServiceCheck statuses
+const (
+	ServiceCheckOK       = 0
+	ServiceCheckWarning  = 1
+	ServiceCheckCritical = 2
+	ServiceCheckUnknown  = 3
+)
+
+// ServiceCheck is a DogStatsD service check, reporting status of the service
+type ServiceCheck struct {
+	Name      string
+	Status    int
+	Timestamp int64
+	Hostname  string
+	Message   string
+	Tags      []Tag
+}
+
+// Validate checks that service check may be encoded to DogStatsD datagram
+func (check ServiceCheck) Validate() error {
+	if check.Name == "" {
+		return newFieldError("name", true, "Service check name not specified")
+	}
+
+	if check.Status < ServiceCheckOK || check.Status > ServiceCheckUnknown {
+		return newFieldError("status", false, "Invalid service check status %d", check.Status)
+	}
+
+	return validateDatagramFields(map[string]string{
+		"name":     check.Name,
+		"hostname": check.Hostname,
+	}, check.Tags)
+}
+
+// datagram encodes service check as "_sc|name|status|d:timestamp|h:hostname|#tags|m:message"
+func (check ServiceCheck) datagram() string {
+	var datagram strings.Builder
+	fmt.Fprintf(&datagram, "_sc|%s|%d", check.Name, check.Status)
+
+	if check.Timestamp != 0 {
+		datagram.WriteString("|d:" + strconv.FormatInt(check.Timestamp, 10))
+	}
+	if check.Hostname != "" {
+		datagram.WriteString("|h:" + check.Hostname)
+	}
+	if len(check.Tags) > 0 {
+		datagram.WriteString("|#" + joinTags(check.Tags, ":", ","))
+	}
+
+	// message must be the last field
+	if check.Message != "" {
+		datagram.WriteString("|m:" + strings.ReplaceAll(escapeDatagramText(check.Message), "m:", "m\\:"))
+	}
+
+	return datagram.String()
+}
+
+// escapeDatagramText escapes line breaks, which are not allowed inside of datagram
+func escapeDatagramText(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\\n"), "\n", "\\n")
+}
+
+// ValidateSetMember checks that member of the set does not break datagram format
+func ValidateSetMember(member string) error {
+	if strings.ContainsAny(member, ":#") {
+		return newFieldError("value", false, "Set member must not contain ':' or '#'")
+	}
+
+	return validateDatagramFields(map[string]string{"value": member}, nil)
+}
+
+// validateDatagramFields checks that fields and tags do not break datagram format
+func validateDatagramFields(fields map[string]string, tags []Tag) error {
+	for name, value := range fields {
+		if strings.ContainsAny(value, "|\r\n") {
+			return newFieldError(name, false, "Field %s must not contain '|' or line breaks", name)
+		}
+	}
+
+	for _, tag := range tags {
+		if tag.Key == "" || strings.ContainsAny(tag.Key+tag.Value, "|,\r\n") {
+			return newFieldError("tags", false, "Invalid tag %q", tag.Key+":"+tag.Value)
+		}
+	}
+
+	return nil
+}
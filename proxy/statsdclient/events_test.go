@@ -0,0 +1,77 @@
+package statsdclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventDatagram(t *testing.T) {
+	event := Event{
+		Title:          "Deploy",
+		Text:           "Version 1.2\nreleased",
+		Timestamp:      1600000000,
+		Hostname:       "web-1",
+		AggregationKey: "deploy",
+		Priority:       "low",
+		SourceType:     "jenkins",
+		AlertType:      "success",
+		Tags:           []Tag{{"env", "prod"}, {"app", "web"}},
+	}
+
+	require := require.New(t)
+
+	require.NoError(event.Validate())
+	require.Equal(
+		"_e{6,21}:Deploy|Version 1.2\\nreleased|d:1600000000|h:web-1|k:deploy|p:low|s:jenkins|t:success|#env:prod,app:web",
+		event.datagram(),
+	)
+
+	require.Equal("_e{6,0}:Deploy|", Event{Title: "Deploy"}.datagram())
+}
+
+func TestEventValidate(t *testing.T) {
+	require := require.New(t)
+
+	require.Error(Event{}.Validate())
+	require.Error(Event{Title: "Deploy", Priority: "high"}.Validate())
+	require.Error(Event{Title: "Deploy", AlertType: "fatal"}.Validate())
+	require.Error(Event{Title: "Deploy", AggregationKey: "a|b"}.Validate())
+	require.Error(Event{Title: "Deploy", Tags: []Tag{{"env", "prod,app:web"}}}.Validate())
+}
+
+func TestServiceCheckDatagram(t *testing.T) {
+	check := ServiceCheck{
+		Name:      "app.can_connect",
+		Status:    ServiceCheckCritical,
+		Timestamp: 1600000000,
+		Hostname:  "web-1",
+		Message:   "Connection refused\nm:retry",
+		Tags:      []Tag{{"env", "prod"}},
+	}
+
+	require := require.New(t)
+
+	require.NoError(check.Validate())
+	require.Equal(
+		"_sc|app.can_connect|2|d:1600000000|h:web-1|#env:prod|m:Connection refused\\nm\\:retry",
+		check.datagram(),
+	)
+}
+
+func TestServiceCheckValidate(t *testing.T) {
+	require := require.New(t)
+
+	require.Error(ServiceCheck{}.Validate())
+	require.Error(ServiceCheck{Name: "app.can_connect", Status: 4}.Validate())
+	require.Error(ServiceCheck{Name: "app|can_connect"}.Validate())
+}
+
+func TestValidateSetMember(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError(ValidateSetMember("f81d4fae-7dec-11d0-a765-00a0c91e6bf6"))
+	for _, member := range []string{"user:42", "user|42", "user#42", "user\r42", "user\n42"} {
+		require.Error(ValidateSetMember(member), member)
+	}
+}
@@ -0,0 +1,211 @@
+package statsdclient
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// hashRingReplicas is a number of points of every node on the ring,
+// which spreads keys evenly between nodes
+const hashRingReplicas = 160
+
+// HashRingNode is a StatsD server in the hash ring
+type HashRingNode struct {
+	// Name places node on the ring, so it must be unique and stable, like address of the node
+	Name   string
+	Client StatsdClientInterface
+	// HealthCheck returns error if node is down. Node without health check is always considered up
+	HealthCheck func() error
+}
+
+type hashRingNode struct {
+	HashRingNode
+	down int32
+}
+
+func (node *hashRingNode) isUp() bool {
+	return atomic.LoadInt32(&node.down) == 0
+}
+
+type hashRingPoint struct {
+	hash      uint32
+	nodeIndex int
+}
+
+// HashRingClient routes every metric key to one of nodes using consistent hashing,
+// so aggregation of the key stays on a single node.
+// Keys of the node, which is down, are routed to next nodes on the ring until it is up again
+type HashRingClient struct {
+	nodes               []*hashRingNode
+	ring                []hashRingPoint
+	healthCheckInterval time.Duration
+	healthCheckStop     chan struct{}
+	healthCheckDone     sync.WaitGroup
+}
+
+// NewHashRingClient creates client, sharding metrics between nodes.
+// Health of nodes is checked every healthCheckInterval
+func NewHashRingClient(nodes []HashRingNode, healthCheckInterval time.Duration) *HashRingClient {
+	hashRingClient := HashRingClient{
+		nodes:               make([]*hashRingNode, len(nodes)),
+		ring:                make([]hashRingPoint, 0, len(nodes)*hashRingReplicas),
+		healthCheckInterval: healthCheckInterval,
+	}
+
+	for nodeIndex, node := range nodes {
+		hashRingClient.nodes[nodeIndex] = &hashRingNode{HashRingNode: node}
+
+		for replica := 0; replica < hashRingReplicas; replica++ {
+			hashRingClient.ring = append(hashRingClient.ring, hashRingPoint{
+				hash:      crc32.ChecksumIEEE([]byte(node.Name + "-" + strconv.Itoa(replica))),
+				nodeIndex: nodeIndex,
+			})
+		}
+	}
+
+	sort.Slice(hashRingClient.ring, func(i, j int) bool {
+		return hashRingClient.ring[i].hash < hashRingClient.ring[j].hash
+	})
+
+	return &hashRingClient
+}
+
+// nodeFor returns index of the first node on the ring after hash of the key, which is up.
+// If all nodes are down, the first node after hash is returned
+func (hashRingClient *HashRingClient) nodeFor(key string) int {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	ringLength := len(hashRingClient.ring)
+
+	position := sort.Search(ringLength, func(i int) bool {
+		return hashRingClient.ring[i].hash >= hash
+	})
+
+	for i := 0; i < ringLength; i++ {
+		nodeIndex := hashRingClient.ring[(position+i)%ringLength].nodeIndex
+		if hashRingClient.nodes[nodeIndex].isUp() {
+			return nodeIndex
+		}
+	}
+
+	return hashRingClient.ring[position%ringLength].nodeIndex
+}
+
+func (hashRingClient *HashRingClient) clientFor(key string) StatsdClientInterface {
+	return hashRingClient.nodes[hashRingClient.nodeFor(key)].Client
+}
+
+// CheckHealth checks health of every node, and takes nodes out of the ring or returns them back
+func (hashRingClient *HashRingClient) CheckHealth() {
+	for _, node := range hashRingClient.nodes {
+		if node.HealthCheck == nil {
+			continue
+		}
+
+		err := node.HealthCheck()
+		if err != nil && atomic.CompareAndSwapInt32(&node.down, 0, 1) {
+			log.WithFields(log.Fields{"Node": node.Name, "Error": err}).Warn("StatsD node is down, removed from the ring")
+		} else if err == nil && atomic.CompareAndSwapInt32(&node.down, 1, 0) {
+			log.WithFields(log.Fields{"Node": node.Name}).Info("StatsD node is up, returned to the ring")
+		}
+	}
+}
+
+func (hashRingClient *HashRingClient) checkHealthOnTick(ticker *time.Ticker, stop chan struct{}) {
+	defer hashRingClient.healthCheckDone.Done()
+
+	for {
+		select {
+		case <-ticker.C:
+			hashRingClient.CheckHealth()
+		case <-stop:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+func (hashRingClient *HashRingClient) Open() {
+	for _, node := range hashRingClient.nodes {
+		node.Client.Open()
+	}
+
+	if hashRingClient.healthCheckInterval > 0 {
+		hashRingClient.CheckHealth()
+
+		hashRingClient.healthCheckStop = make(chan struct{})
+		hashRingClient.healthCheckDone.Add(1)
+		go hashRingClient.checkHealthOnTick(time.NewTicker(hashRingClient.healthCheckInterval), hashRingClient.healthCheckStop)
+	}
+}
+
+func (hashRingClient *HashRingClient) Close() {
+	if hashRingClient.healthCheckStop != nil {
+		close(hashRingClient.healthCheckStop)
+		hashRingClient.healthCheckDone.Wait()
+		hashRingClient.healthCheckStop = nil
+	}
+
+	for _, node := range hashRingClient.nodes {
+		node.Client.Close()
+	}
+}
+
+func (hashRingClient *HashRingClient) Count(key string, value int, sampleRate float32, tags []Tag) {
+	hashRingClient.clientFor(key).Count(key, value, sampleRate, tags)
+}
+
+func (hashRingClient *HashRingClient) Timing(key string, time float64, sampleRate float32, tags []Tag) {
+	hashRingClient.clientFor(key).Timing(key, time, sampleRate, tags)
+}
+
+func (hashRingClient *HashRingClient) Gauge(key string, value float64, tags []Tag) {
+	hashRingClient.clientFor(key).Gauge(key, value, tags)
+}
+
+func (hashRingClient *HashRingClient) GaugeShift(key string, value float64, tags []Tag) {
+	hashRingClient.clientFor(key).GaugeShift(key, value, tags)
+}
+
+func (hashRingClient *HashRingClient) Set(key string, value string, tags []Tag) {
+	hashRingClient.clientFor(key).Set(key, value, tags)
+}
+
+func (hashRingClient *HashRingClient) Histogram(key string, value float64, sampleRate float32, tags []Tag) {
+	hashRingClient.clientFor(key).Histogram(key, value, sampleRate, tags)
+}
+
+func (hashRingClient *HashRingClient) Distribution(key string, value float64, sampleRate float32, tags []Tag) {
+	hashRingClient.clientFor(key).Distribution(key, value, sampleRate, tags)
+}
+
+// Event is routed by aggregation key, or by title if aggregation key not specified
+func (hashRingClient *HashRingClient) Event(event Event) {
+	key := event.AggregationKey
+	if key == "" {
+		key = event.Title
+	}
+
+	hashRingClient.clientFor(key).Event(event)
+}
+
+// ServiceCheck is routed by name of the check
+func (hashRingClient *HashRingClient) ServiceCheck(check ServiceCheck) {
+	hashRingClient.clientFor(check.Name).ServiceCheck(check)
+}
+
+// Raw metric is routed by key, which is part of the line before value
+func (hashRingClient *HashRingClient) Raw(metric string) {
+	key := metric
+	if separatorPosition := strings.Index(metric, ":"); separatorPosition != -1 {
+		key = metric[:separatorPosition]
+	}
+
+	hashRingClient.clientFor(key).Raw(metric)
+}
@@ -0,0 +1,140 @@
+package statsdclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHashRingClient(nodeHealth []error) *HashRingClient {
+	nodes := make([]HashRingNode, len(nodeHealth))
+	for i := range nodeHealth {
+		nodeIndex := i
+		nodes[i] = HashRingNode{
+			Name:        fmt.Sprintf("statsd-%d:8125", i),
+			Client:      NewClient("127.0.0.1", 8125, TagFormatInflux),
+			HealthCheck: func() error { return nodeHealth[nodeIndex] },
+		}
+	}
+
+	return NewHashRingClient(nodes, 0)
+}
+
+func TestHashRingClientSpreadsKeys(t *testing.T) {
+	require := require.New(t)
+
+	client := newTestHashRingClient(make([]error, 3))
+
+	keysOfNode := make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("some.key.%d", i)
+		nodeIndex := client.nodeFor(key)
+		require.Equal(nodeIndex, client.nodeFor(key), "Key must be routed to the same node")
+		keysOfNode[nodeIndex]++
+	}
+
+	for _, keys := range keysOfNode {
+		require.InDelta(1000, keys, 300)
+	}
+}
+
+func TestHashRingClientRemovesDeadNodes(t *testing.T) {
+	require := require.New(t)
+
+	nodeHealth := make([]error, 3)
+	client := newTestHashRingClient(nodeHealth)
+
+	nodesBefore := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("some.key.%d", i)
+		nodesBefore[key] = client.nodeFor(key)
+	}
+
+	// keys of dead node move to other nodes, other keys stay
+	nodeHealth[1] = errors.New("connection refused")
+	client.CheckHealth()
+
+	for key, nodeBefore := range nodesBefore {
+		nodeAfter := client.nodeFor(key)
+		require.NotEqual(1, nodeAfter, key)
+		if nodeBefore != 1 {
+			require.Equal(nodeBefore, nodeAfter, key)
+		}
+	}
+
+	// recovered node receives its keys back
+	nodeHealth[1] = nil
+	client.CheckHealth()
+
+	for key, nodeBefore := range nodesBefore {
+		require.Equal(nodeBefore, client.nodeFor(key), key)
+	}
+}
+
+func TestHashRingClientSendsMetricToOneNode(t *testing.T) {
+	require := require.New(t)
+
+	servers := make([]*net.UDPConn, 2)
+	nodes := make([]HashRingNode, 2)
+	for i := range nodes {
+		server, port := newTestServer(t)
+		servers[i] = server
+		nodes[i] = HashRingNode{
+			Name:   fmt.Sprintf("127.0.0.1:%d", port),
+			Client: NewClient("127.0.0.1", port, TagFormatInflux),
+		}
+	}
+
+	client := NewHashRingClient(nodes, 0)
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 1, nil)
+	client.Raw("clicks:2|c")
+
+	server := servers[client.nodeFor("clicks")]
+	require.Equal("clicks:1|c", readPacket(t, server))
+	require.Equal("clicks:2|c", readPacket(t, server))
+
+	otherServer := servers[1-client.nodeFor("clicks")]
+	require.NoError(otherServer.SetReadDeadline(time.Now().Add(50 * time.Millisecond)))
+	_, err := otherServer.Read(make([]byte, 1024))
+	require.Error(err, "Metric must be sent to one node only")
+}
+
+func TestAdminHealthCheck(t *testing.T) {
+	require := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+
+	status := make(chan string, 2)
+	status <- "up"
+	status <- "down"
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Read(make([]byte, 64))
+			conn.Write([]byte("health: " + <-status + "\n"))
+			conn.Close()
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	healthCheck := NewAdminHealthCheck("127.0.0.1", port, time.Second)
+
+	require.NoError(healthCheck())
+	require.Error(healthCheck())
+
+	listener.Close()
+	require.Error(healthCheck())
+}
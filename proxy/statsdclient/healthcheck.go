@@ -0,0 +1,43 @@
+package statsdclient
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewAdminHealthCheck creates health check, asking "health" command of StatsD admin interface by TCP.
+// StatsD answers "health: up" if it accepts metrics
+func NewAdminHealthCheck(host string, adminPort int, timeout time.Duration) func() error {
+	address := net.JoinHostPort(host, strconv.Itoa(adminPort))
+
+	return func() error {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+
+		if _, err := conn.Write([]byte("health\n")); err != nil {
+			return err
+		}
+
+		response, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(response) != "health: up" {
+			return fmt.Errorf("Unexpected health status %q", strings.TrimSpace(response))
+		}
+
+		return nil
+	}
+}
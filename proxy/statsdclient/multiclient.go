@@ -0,0 +1,83 @@
+package statsdclient
+
+// MultiClient mirrors every metric to all of its clients
+type MultiClient struct {
+	clients []StatsdClientInterface
+}
+
+// NewMultiClient creates client, sending metrics to all passed clients
+func NewMultiClient(clients ...StatsdClientInterface) *MultiClient {
+	return &MultiClient{clients}
+}
+
+func (multiClient *MultiClient) Open() {
+	for _, client := range multiClient.clients {
+		client.Open()
+	}
+}
+
+func (multiClient *MultiClient) Close() {
+	for _, client := range multiClient.clients {
+		client.Close()
+	}
+}
+
+func (multiClient *MultiClient) Count(key string, value int, sampleRate float32, tags []Tag) {
+	for _, client := range multiClient.clients {
+		client.Count(key, value, sampleRate, tags)
+	}
+}
+
+func (multiClient *MultiClient) Timing(key string, time float64, sampleRate float32, tags []Tag) {
+	for _, client := range multiClient.clients {
+		client.Timing(key, time, sampleRate, tags)
+	}
+}
+
+func (multiClient *MultiClient) Gauge(key string, value float64, tags []Tag) {
+	for _, client := range multiClient.clients {
+		client.Gauge(key, value, tags)
+	}
+}
+
+func (multiClient *MultiClient) GaugeShift(key string, value float64, tags []Tag) {
+	for _, client := range multiClient.clients {
+		client.GaugeShift(key, value, tags)
+	}
+}
+
+func (multiClient *MultiClient) Set(key string, value string, tags []Tag) {
+	for _, client := range multiClient.clients {
+		client.Set(key, value, tags)
+	}
+}
+
+func (multiClient *MultiClient) Histogram(key string, value float64, sampleRate float32, tags []Tag) {
+	for _, client := range multiClient.clients {
+		client.Histogram(key, value, sampleRate, tags)
+	}
+}
+
+func (multiClient *MultiClient) Distribution(key string, value float64, sampleRate float32, tags []Tag) {
+	for _, client := range multiClient.clients {
+		client.Distribution(key, value, sampleRate, tags)
+	}
+}
+
+func (multiClient *MultiClient) Event(event Event) {
+	for _, client := range multiClient.clients {
+		client.Event(event)
+	}
+}
+
+func (multiClient *MultiClient) ServiceCheck(check ServiceCheck) {
+	for _, client := range multiClient.clients {
+		client.ServiceCheck(check)
+	}
+}
+
+func (multiClient *MultiClient) Raw(metric string) {
+	for _, client := range multiClient.clients {
+		client.Raw(metric)
+	}
+}
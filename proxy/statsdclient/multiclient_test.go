@@ -0,0 +1,33 @@
+package statsdclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiClientMirrorsMetrics(t *testing.T) {
+	graphiteServer, graphitePort := newTestServer(t)
+	datadogServer, datadogPort := newTestServer(t)
+
+	client := NewMultiClient(
+		NewClient("127.0.0.1", graphitePort, TagFormatGraphite),
+		NewClient("127.0.0.1", datadogPort, TagFormatDogStatsD),
+	)
+	client.Open()
+	defer client.Close()
+
+	require := require.New(t)
+
+	client.Count("clicks", 1, 0.5, []Tag{{"env", "prod"}})
+	require.Equal("clicks;env=prod:1|c|@0.5", readPacket(t, graphiteServer))
+	require.Equal("clicks:1|c|@0.5|#env:prod", readPacket(t, datadogServer))
+
+	client.Event(Event{Title: "Deploy"})
+	require.Equal("_e{6,0}:Deploy|", readPacket(t, graphiteServer))
+	require.Equal("_e{6,0}:Deploy|", readPacket(t, datadogServer))
+
+	client.Raw("visitors:42|s")
+	require.Equal("visitors:42|s", readPacket(t, graphiteServer))
+	require.Equal("visitors:42|s", readPacket(t, datadogServer))
+}
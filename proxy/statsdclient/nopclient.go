@@ -0,0 +1,28 @@
+package statsdclient
+
+// NopClient discards all metrics, and is used when metrics are not sent to StatsD
+type NopClient struct{}
+
+func (client NopClient) Open() {}
+
+func (client NopClient) Close() {}
+
+func (client NopClient) Count(key string, value int, sampleRate float32, tags []Tag) {}
+
+func (client NopClient) Timing(key string, time float64, sampleRate float32, tags []Tag) {}
+
+func (client NopClient) Gauge(key string, value float64, tags []Tag) {}
+
+func (client NopClient) GaugeShift(key string, value float64, tags []Tag) {}
+
+func (client NopClient) Set(key string, value string, tags []Tag) {}
+
+func (client NopClient) Histogram(key string, value float64, sampleRate float32, tags []Tag) {}
+
+func (client NopClient) Distribution(key string, value float64, sampleRate float32, tags []Tag) {}
+
+func (client NopClient) Event(event Event) {}
+
+func (client NopClient) ServiceCheck(check ServiceCheck) {}
+
+func (client NopClient) Raw(metric string) {}
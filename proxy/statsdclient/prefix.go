@@ -0,0 +1,41 @@
+package statsdclient
+
+// PrefixClient adds prefix to keys of metrics, passed to the wrapped client.
+// Events, service checks and raw metrics are passed as is
+type PrefixClient struct {
+	StatsdClientInterface
+	prefix string
+}
+
+// NewPrefixClient wraps client with prefixing of metric keys
+func NewPrefixClient(prefix string, client StatsdClientInterface) *PrefixClient {
+	return &PrefixClient{client, prefix}
+}
+
+func (client *PrefixClient) Count(key string, value int, sampleRate float32, tags []Tag) {
+	client.StatsdClientInterface.Count(client.prefix+key, value, sampleRate, tags)
+}
+
+func (client *PrefixClient) Timing(key string, time float64, sampleRate float32, tags []Tag) {
+	client.StatsdClientInterface.Timing(client.prefix+key, time, sampleRate, tags)
+}
+
+func (client *PrefixClient) Gauge(key string, value float64, tags []Tag) {
+	client.StatsdClientInterface.Gauge(client.prefix+key, value, tags)
+}
+
+func (client *PrefixClient) GaugeShift(key string, value float64, tags []Tag) {
+	client.StatsdClientInterface.GaugeShift(client.prefix+key, value, tags)
+}
+
+func (client *PrefixClient) Set(key string, value string, tags []Tag) {
+	client.StatsdClientInterface.Set(client.prefix+key, value, tags)
+}
+
+func (client *PrefixClient) Histogram(key string, value float64, sampleRate float32, tags []Tag) {
+	client.StatsdClientInterface.Histogram(client.prefix+key, value, sampleRate, tags)
+}
+
+func (client *PrefixClient) Distribution(key string, value float64, sampleRate float32, tags []Tag) {
+	client.StatsdClientInterface.Distribution(client.prefix+key, value, sampleRate, tags)
+}
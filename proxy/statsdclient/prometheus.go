@@ -0,0 +1,388 @@
+package statsdclient
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPrometheusBuckets are upper bounds of histogram buckets, same as default buckets of Prometheus client
+var DefaultPrometheusBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// prometheusSummaryQuantiles are quantiles of summaries
+var prometheusSummaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// prometheusSummaryMaxSamples is a number of last observations of summary, used to compute quantiles
+const prometheusSummaryMaxSamples = 1024
+
+// prometheusSetWindow is an interval, over which unique members of set are counted
+const prometheusSetWindow = time.Minute
+
+const (
+	prometheusTypeCounter   = "counter"
+	prometheusTypeGauge     = "gauge"
+	prometheusTypeHistogram = "histogram"
+	prometheusTypeSummary   = "summary"
+)
+
+type prometheusSeries struct {
+	labels []Tag
+	// value of counter or gauge
+	value float64
+	// observations of histogram and summary
+	bucketCounts []float64
+	count        float64
+	sum          float64
+	samples      []float64
+	nextSample   int
+	// members of set in current window, and number of members in previous one
+	members         map[string]struct{}
+	windowStart     time.Time
+	previousMembers int
+}
+
+type prometheusFamily struct {
+	name       string
+	metricType string
+	series     map[string]*prometheusSeries
+}
+
+// PrometheusClient keeps metrics in memory, and exposes them in Prometheus text format.
+// Counts are exposed as counters, gauges as gauges, timings in seconds and histograms as histograms,
+// distributions as summaries and sets as gauges with number of unique members over the last minute
+type PrometheusClient struct {
+	buckets  []float64
+	lock     sync.Mutex
+	families map[string]*prometheusFamily
+	// new series are dropped, when maxSeries series are kept
+	maxSeries       int
+	seriesCount     int
+	onDroppedSeries func(name string)
+}
+
+// NewPrometheusClient creates client, exposing histograms with passed buckets.
+// Number of kept series is limited by maxSeries, 0 to keep any number of series
+func NewPrometheusClient(buckets []float64, maxSeries int) *PrometheusClient {
+	if len(buckets) == 0 {
+		buckets = DefaultPrometheusBuckets
+	}
+
+	sortedBuckets := append([]float64(nil), buckets...)
+	sort.Float64s(sortedBuckets)
+
+	return &PrometheusClient{
+		buckets:   sortedBuckets,
+		families:  make(map[string]*prometheusFamily),
+		maxSeries: maxSeries,
+	}
+}
+
+// SetDroppedSeriesHandler sets function, called with metric name on every metric dropped by limit of series.
+// Handler is called under the lock, so it must not send metrics to the same client
+func (client *PrometheusClient) SetDroppedSeriesHandler(handler func(name string)) {
+	client.onDroppedSeries = handler
+}
+
+func (client *PrometheusClient) Open() {}
+
+func (client *PrometheusClient) Close() {}
+
+// sanitizePrometheusName replaces characters, not allowed in Prometheus names, with "_"
+func sanitizePrometheusName(name string) string {
+	sanitized := []byte(name)
+	for i, char := range sanitized {
+		isLetter := char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char == '_'
+		isDigit := char >= '0' && char <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			sanitized[i] = '_'
+		}
+	}
+
+	return string(sanitized)
+}
+
+// series returns series of metric with tags, creating it if not exists.
+// Nil is returned if metric with same name has another type, or limit of series is reached.
+// Must be called under the lock
+func (client *PrometheusClient) series(key string, suffix string, metricType string, tags []Tag) *prometheusSeries {
+	name := sanitizePrometheusName(key) + suffix
+
+	family, familyExists := client.families[name]
+	if familyExists && family.metricType != metricType {
+		log.WithFields(log.Fields{"Metric": name, "Type": family.metricType}).Debug("Prometheus metric already has another type")
+		return nil
+	}
+
+	labels := make([]Tag, len(tags))
+	for i, tag := range tags {
+		labels[i] = Tag{Key: sanitizePrometheusName(tag.Key), Value: tag.Value}
+	}
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].Key < labels[j].Key })
+
+	// tag keys like "a.b" and "a_b" become the same label, which is not allowed twice in series
+	for i := 1; i < len(labels); i++ {
+		if labels[i].Key == labels[i-1].Key {
+			log.WithFields(log.Fields{"Metric": name, "Label": labels[i].Key}).Debug("Prometheus metric has duplicate labels")
+			return nil
+		}
+	}
+
+	id := joinTags(labels, "=", ",")
+	if familyExists {
+		if series, ok := family.series[id]; ok {
+			return series
+		}
+	}
+
+	// keys and tags with unbounded values must not exhaust memory
+	if client.maxSeries > 0 && client.seriesCount >= client.maxSeries {
+		log.WithFields(log.Fields{"Metric": name, "MaxSeries": client.maxSeries}).Debug("Limit of Prometheus series reached")
+		if client.onDroppedSeries != nil {
+			client.onDroppedSeries(name)
+		}
+		return nil
+	}
+
+	if !familyExists {
+		family = &prometheusFamily{name: name, metricType: metricType, series: make(map[string]*prometheusSeries)}
+		client.families[name] = family
+	}
+
+	series := &prometheusSeries{labels: labels}
+	family.series[id] = series
+	client.seriesCount++
+
+	return series
+}
+
+// Count increases counter. Negative values are skipped, because Prometheus counters never decrease
+func (client *PrometheusClient) Count(key string, value int, sampleRate float32, tags []Tag) {
+	if value < 0 {
+		log.WithFields(log.Fields{"Metric": key}).Debug("Negative counts are not exposed to Prometheus")
+		return
+	}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	if series := client.series(key, "_total", prometheusTypeCounter, tags); series != nil {
+		series.value += float64(value) / sampleRateOrOne(sampleRate)
+	}
+}
+
+func (client *PrometheusClient) Gauge(key string, value float64, tags []Tag) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	if series := client.series(key, "", prometheusTypeGauge, tags); series != nil {
+		series.value = value
+	}
+}
+
+func (client *PrometheusClient) GaugeShift(key string, value float64, tags []Tag) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	if series := client.series(key, "", prometheusTypeGauge, tags); series != nil {
+		series.value += value
+	}
+}
+
+// Timing is converted from milliseconds to seconds
+func (client *PrometheusClient) Timing(key string, time float64, sampleRate float32, tags []Tag) {
+	client.observeHistogram(key, "_seconds", time/1000, sampleRate, tags)
+}
+
+func (client *PrometheusClient) Histogram(key string, value float64, sampleRate float32, tags []Tag) {
+	client.observeHistogram(key, "", value, sampleRate, tags)
+}
+
+func (client *PrometheusClient) observeHistogram(key string, suffix string, value float64, sampleRate float32, tags []Tag) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	series := client.series(key, suffix, prometheusTypeHistogram, tags)
+	if series == nil {
+		return
+	}
+
+	if series.bucketCounts == nil {
+		series.bucketCounts = make([]float64, len(client.buckets))
+	}
+
+	weight := 1 / sampleRateOrOne(sampleRate)
+	for i, upperBound := range client.buckets {
+		if value <= upperBound {
+			series.bucketCounts[i] += weight
+		}
+	}
+	series.count += weight
+	series.sum += value * weight
+}
+
+func (client *PrometheusClient) Distribution(key string, value float64, sampleRate float32, tags []Tag) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	series := client.series(key, "", prometheusTypeSummary, tags)
+	if series == nil {
+		return
+	}
+
+	weight := 1 / sampleRateOrOne(sampleRate)
+	series.count += weight
+	series.sum += value * weight
+
+	// keep last observations to compute quantiles
+	if len(series.samples) < prometheusSummaryMaxSamples {
+		series.samples = append(series.samples, value)
+	} else {
+		series.samples[series.nextSample] = value
+		series.nextSample = (series.nextSample + 1) % prometheusSummaryMaxSamples
+	}
+}
+
+func (client *PrometheusClient) Set(key string, value string, tags []Tag) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	series := client.series(key, "", prometheusTypeGauge, tags)
+	if series == nil {
+		return
+	}
+
+	series.rotateSetWindow(time.Now())
+	series.members[value] = struct{}{}
+}
+
+// rotateSetWindow starts new window of set members, if current one is over
+func (series *prometheusSeries) rotateSetWindow(now time.Time) {
+	if series.members == nil {
+		series.members = make(map[string]struct{})
+		series.windowStart = now.Truncate(prometheusSetWindow)
+		return
+	}
+
+	elapsed := now.Sub(series.windowStart)
+	if elapsed < prometheusSetWindow {
+		return
+	}
+
+	series.previousMembers = len(series.members)
+	if elapsed >= 2*prometheusSetWindow {
+		series.previousMembers = 0
+	}
+	series.members = make(map[string]struct{})
+	series.windowStart = now.Truncate(prometheusSetWindow)
+}
+
+// Event is not supported by Prometheus, and skipped
+func (client *PrometheusClient) Event(event Event) {
+	log.WithFields(log.Fields{"Title": event.Title}).Debug("Events are not exposed to Prometheus")
+}
+
+// ServiceCheck is not supported by Prometheus, and skipped
+func (client *PrometheusClient) ServiceCheck(check ServiceCheck) {
+	log.WithFields(log.Fields{"Name": check.Name}).Debug("Service checks are not exposed to Prometheus")
+}
+
+// Raw metric is not parsed, and skipped
+func (client *PrometheusClient) Raw(metric string) {
+	log.WithFields(log.Fields{"Metric": metric}).Debug("Raw metrics are not exposed to Prometheus")
+}
+
+// ServeHTTP writes metrics in Prometheus text exposition format
+func (client *PrometheusClient) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if err := client.WriteMetrics(w); err != nil {
+		log.WithFields(log.Fields{"Error": err}).Error("Cannot write Prometheus metrics")
+	}
+}
+
+// WriteMetrics writes metrics in Prometheus text exposition format
+func (client *PrometheusClient) WriteMetrics(writer io.Writer) error {
+	output := bufio.NewWriter(writer)
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	names := make([]string, 0, len(client.families))
+	for name := range client.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+
+	for _, name := range names {
+		family := client.families[name]
+		output.WriteString("# TYPE " + name + " " + family.metricType + "\n")
+
+		ids := make([]string, 0, len(family.series))
+		for id := range family.series {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			series := family.series[id]
+
+			switch {
+			case family.metricType == prometheusTypeHistogram:
+				for i, upperBound := range client.buckets {
+					writePrometheusSample(output, name+"_bucket", series.labels, &Tag{"le", formatFloat(upperBound)}, series.bucketCounts[i])
+				}
+				writePrometheusSample(output, name+"_bucket", series.labels, &Tag{"le", "+Inf"}, series.count)
+				writePrometheusSample(output, name+"_sum", series.labels, nil, series.sum)
+				writePrometheusSample(output, name+"_count", series.labels, nil, series.count)
+			case family.metricType == prometheusTypeSummary:
+				samples := append([]float64(nil), series.samples...)
+				sort.Float64s(samples)
+				for _, quantile := range prometheusSummaryQuantiles {
+					rank := int(math.Ceil(quantile*float64(len(samples)))) - 1
+					if rank < 0 {
+						rank = 0
+					}
+					writePrometheusSample(output, name, series.labels, &Tag{"quantile", formatFloat(quantile)}, samples[rank])
+				}
+				writePrometheusSample(output, name+"_sum", series.labels, nil, series.sum)
+				writePrometheusSample(output, name+"_count", series.labels, nil, series.count)
+			case series.members != nil:
+				series.rotateSetWindow(now)
+				writePrometheusSample(output, name, series.labels, nil, float64(series.previousMembers))
+			default:
+				writePrometheusSample(output, name, series.labels, nil, series.value)
+			}
+		}
+	}
+
+	return output.Flush()
+}
+
+// prometheusLabelValueReplacer escapes label values
+var prometheusLabelValueReplacer = strings.NewReplacer("\\", `\\`, "\n", `\n`, "\"", `\"`)
+
+func writePrometheusSample(output *bufio.Writer, name string, labels []Tag, extraLabel *Tag, value float64) {
+	output.WriteString(name)
+
+	if len(labels) > 0 || extraLabel != nil {
+		pairs := make([]string, 0, len(labels)+1)
+		for _, label := range labels {
+			pairs = append(pairs, label.Key+"=\""+prometheusLabelValueReplacer.Replace(label.Value)+"\"")
+		}
+		if extraLabel != nil {
+			pairs = append(pairs, extraLabel.Key+"=\""+extraLabel.Value+"\"")
+		}
+		output.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	output.WriteString(" " + formatFloat(value) + "\n")
+}
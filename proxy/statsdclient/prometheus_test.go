@@ -0,0 +1,127 @@
+package statsdclient
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusClientExposesMetrics(t *testing.T) {
+	require := require.New(t)
+
+	client := NewPrometheusClient([]float64{0.1, 1}, 0)
+
+	client.Count("page.clicks", 1, 1, []Tag{{"locale", "en-us"}, {"env", "prod"}})
+	client.Count("page.clicks", 1, 0.5, []Tag{{"env", "prod"}, {"locale", "en-us"}})
+	client.Gauge("memory", 512, nil)
+	client.GaugeShift("memory", -12, nil)
+	client.Timing("render", 50, 1, []Tag{{"page", "say \"hi\""}})
+	client.Timing("render", 500, 1, []Tag{{"page", "say \"hi\""}})
+	client.Distribution("latency", 10, 1, nil)
+	client.Distribution("latency", 20, 1, nil)
+	client.Set("visitors", "a", nil)
+
+	// metric with name of another type is skipped
+	client.Gauge("page.clicks_total", 1, nil)
+
+	var output bytes.Buffer
+	require.NoError(client.WriteMetrics(&output))
+
+	require.Equal(
+		"# TYPE latency summary\n"+
+			"latency{quantile=\"0.5\"} 10\n"+
+			"latency{quantile=\"0.9\"} 20\n"+
+			"latency{quantile=\"0.99\"} 20\n"+
+			"latency_sum 30\n"+
+			"latency_count 2\n"+
+			"# TYPE memory gauge\n"+
+			"memory 500\n"+
+			"# TYPE page_clicks_total counter\n"+
+			"page_clicks_total{env=\"prod\",locale=\"en-us\"} 3\n"+
+			"# TYPE render_seconds histogram\n"+
+			"render_seconds_bucket{page=\"say \\\"hi\\\"\",le=\"0.1\"} 1\n"+
+			"render_seconds_bucket{page=\"say \\\"hi\\\"\",le=\"1\"} 2\n"+
+			"render_seconds_bucket{page=\"say \\\"hi\\\"\",le=\"+Inf\"} 2\n"+
+			"render_seconds_sum{page=\"say \\\"hi\\\"\"} 0.55\n"+
+			"render_seconds_count{page=\"say \\\"hi\\\"\"} 2\n"+
+			"# TYPE visitors gauge\n"+
+			"visitors 0\n",
+		output.String(),
+	)
+}
+
+func TestPrometheusClientCountsSetMembersOverWindow(t *testing.T) {
+	require := require.New(t)
+
+	series := &prometheusSeries{}
+	start := time.Now().Truncate(prometheusSetWindow)
+
+	series.rotateSetWindow(start)
+	series.members["a"] = struct{}{}
+	series.members["b"] = struct{}{}
+	series.members["a"] = struct{}{}
+
+	series.rotateSetWindow(start.Add(prometheusSetWindow))
+	require.Equal(2, series.previousMembers)
+
+	series.rotateSetWindow(start.Add(5 * prometheusSetWindow))
+	require.Equal(0, series.previousMembers)
+}
+
+func TestPrometheusClientServesMetrics(t *testing.T) {
+	require := require.New(t)
+
+	client := NewPrometheusClient(nil, 0)
+	client.Count("clicks", 1, 1, nil)
+
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(http.StatusOK, recorder.Code)
+	require.Equal("text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	require.Equal("# TYPE clicks_total counter\nclicks_total 1\n", recorder.Body.String())
+}
+
+func TestPrometheusClientLimitsSeries(t *testing.T) {
+	require := require.New(t)
+
+	client := NewPrometheusClient(nil, 2)
+
+	var droppedSeries []string
+	client.SetDroppedSeriesHandler(func(name string) {
+		droppedSeries = append(droppedSeries, name)
+	})
+
+	client.Count("clicks", 1, 1, []Tag{{"user", "1"}})
+	client.Count("clicks", 1, 1, []Tag{{"user", "2"}})
+	client.Count("clicks", 1, 1, []Tag{{"user", "3"}})
+	client.Gauge("memory", 512, nil)
+
+	// existing series are still updated
+	client.Count("clicks", 1, 1, []Tag{{"user", "1"}})
+
+	var output bytes.Buffer
+	require.NoError(client.WriteMetrics(&output))
+	require.Equal(
+		"# TYPE clicks_total counter\nclicks_total{user=\"1\"} 2\nclicks_total{user=\"2\"} 1\n",
+		output.String(),
+	)
+	require.Equal([]string{"clicks_total", "memory"}, droppedSeries)
+}
+
+func TestPrometheusClientSkipsNegativeCountsAndDuplicateLabels(t *testing.T) {
+	require := require.New(t)
+
+	client := NewPrometheusClient(nil, 0)
+	client.Count("clicks", 2, 1, nil)
+	client.Count("clicks", -1, 1, nil)
+	client.Gauge("memory", 512, []Tag{{"a.b", "1"}, {"a_b", "2"}})
+
+	var output bytes.Buffer
+	require.NoError(client.WriteMetrics(&output))
+	require.Equal("# TYPE clicks_total counter\nclicks_total 2\n", output.String())
+}
@@ -0,0 +1,48 @@
+package statsdclient
+
+import "math/rand"
+
+// SamplingClient skips metrics according to their sample rate,
+// and passes accepted metrics with the rate to the wrapped client.
+// Sampling decision is made once, so all backends behind the wrapped client receive identical metrics
+type SamplingClient struct {
+	StatsdClientInterface
+}
+
+// NewSamplingClient wraps client with sampling of metrics
+func NewSamplingClient(client StatsdClientInterface) *SamplingClient {
+	return &SamplingClient{client}
+}
+
+// isAcceptedBySampleRate checks if metric must be sent according to sample rate
+func isAcceptedBySampleRate(sampleRate float32) bool {
+	return sampleRate >= 1 || rand.Float32() <= sampleRate
+}
+
+// Count tracks counter with sampling
+func (client *SamplingClient) Count(key string, value int, sampleRate float32, tags []Tag) {
+	if isAcceptedBySampleRate(sampleRate) {
+		client.StatsdClientInterface.Count(key, value, sampleRate, tags)
+	}
+}
+
+// Timing tracks time in milliseconds with sampling
+func (client *SamplingClient) Timing(key string, time float64, sampleRate float32, tags []Tag) {
+	if isAcceptedBySampleRate(sampleRate) {
+		client.StatsdClientInterface.Timing(key, time, sampleRate, tags)
+	}
+}
+
+// Histogram tracks value for percentile aggregation with sampling
+func (client *SamplingClient) Histogram(key string, value float64, sampleRate float32, tags []Tag) {
+	if isAcceptedBySampleRate(sampleRate) {
+		client.StatsdClientInterface.Histogram(key, value, sampleRate, tags)
+	}
+}
+
+// Distribution tracks value for global percentile aggregation with sampling
+func (client *SamplingClient) Distribution(key string, value float64, sampleRate float32, tags []Tag) {
+	if isAcceptedBySampleRate(sampleRate) {
+		client.StatsdClientInterface.Distribution(key, value, sampleRate, tags)
+	}
+}
@@ -0,0 +1,23 @@
+package statsdclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplingClientSkipsMetricsBySampleRate(t *testing.T) {
+	server, port := newTestServer(t)
+
+	client := NewSamplingClient(NewClient("127.0.0.1", port, TagFormatInflux))
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 0, nil)
+	client.Timing("render", 1, 0, nil)
+	client.Histogram("latency", 1, 0, nil)
+	client.Distribution("latency", 1, 0, nil)
+	client.Count("clicks", 2, 1, nil)
+
+	require.Equal(t, "clicks:2|c", readPacket(t, server))
+}
@@ -0,0 +1,131 @@
+package statsdclient
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StatsdSink sends aggregates to StatsD client: counters as counts, gauges as gauges,
+// and derived values of timers and sets as gauges with suffixes, like "key.mean" or "key.upper_90"
+type StatsdSink struct {
+	client StatsdClientInterface
+}
+
+// NewStatsdSink creates sink, sending aggregates to StatsD client
+func NewStatsdSink(client StatsdClientInterface) *StatsdSink {
+	return &StatsdSink{client}
+}
+
+func (sink *StatsdSink) Flush(aggregates Aggregates) {
+	for _, counter := range aggregates.Counters {
+		sink.client.Count(counter.Key, int(math.Round(counter.Count)), 1, counter.Tags)
+	}
+
+	for _, gauge := range aggregates.Gauges {
+		sink.client.Gauge(gauge.Key, gauge.Value, gauge.Tags)
+	}
+
+	for _, timer := range aggregates.Timers {
+		sink.client.Count(timer.Key+".count", int(math.Round(timer.Count)), 1, timer.Tags)
+		sink.client.Gauge(timer.Key+".sum", timer.Sum, timer.Tags)
+		sink.client.Gauge(timer.Key+".mean", timer.Mean, timer.Tags)
+		sink.client.Gauge(timer.Key+".upper", timer.Upper, timer.Tags)
+		sink.client.Gauge(timer.Key+".lower", timer.Lower, timer.Tags)
+		for _, percentile := range timer.Percentiles {
+			sink.client.Gauge(timer.Key+".upper_"+percentileSuffix(percentile.Percentile), percentile.Upper, timer.Tags)
+		}
+	}
+
+	for _, set := range aggregates.Sets {
+		sink.client.Gauge(set.Key+".count", float64(set.Count), set.Tags)
+	}
+}
+
+// GraphiteSink sends aggregates to Graphite by plaintext protocol, like StatsD server does.
+// Tags are sent in Graphite format "key.count;tag=value"
+type GraphiteSink struct {
+	address string
+}
+
+// NewGraphiteSink creates sink, sending aggregates to Graphite at "host:port"
+func NewGraphiteSink(address string) *GraphiteSink {
+	return &GraphiteSink{address}
+}
+
+func (sink *GraphiteSink) Flush(aggregates Aggregates) {
+	var lines bytes.Buffer
+	timestamp := strconv.FormatInt(aggregates.Timestamp.Unix(), 10)
+
+	writeLine := func(key string, suffix string, tags []Tag, value float64) {
+		lines.WriteString(key + suffix)
+		if len(tags) > 0 {
+			lines.WriteString(";" + joinTags(tags, "=", ";"))
+		}
+		lines.WriteString(" " + formatFloat(value) + " " + timestamp + "\n")
+	}
+
+	for _, counter := range aggregates.Counters {
+		writeLine(counter.Key, ".count", counter.Tags, counter.Count)
+		writeLine(counter.Key, ".rate", counter.Tags, counter.Rate)
+	}
+
+	for _, gauge := range aggregates.Gauges {
+		writeLine(gauge.Key, "", gauge.Tags, gauge.Value)
+	}
+
+	for _, timer := range aggregates.Timers {
+		writeLine(timer.Key, ".count", timer.Tags, timer.Count)
+		writeLine(timer.Key, ".rate", timer.Tags, timer.Rate)
+		writeLine(timer.Key, ".sum", timer.Tags, timer.Sum)
+		writeLine(timer.Key, ".mean", timer.Tags, timer.Mean)
+		writeLine(timer.Key, ".upper", timer.Tags, timer.Upper)
+		writeLine(timer.Key, ".lower", timer.Tags, timer.Lower)
+		for _, percentile := range timer.Percentiles {
+			writeLine(timer.Key, ".upper_"+percentileSuffix(percentile.Percentile), timer.Tags, percentile.Upper)
+		}
+	}
+
+	for _, set := range aggregates.Sets {
+		writeLine(set.Key, ".count", set.Tags, float64(set.Count))
+	}
+
+	conn, err := net.DialTimeout("tcp", sink.address, dialTimeout)
+	if err != nil {
+		log.WithFields(log.Fields{"Address": sink.address, "Error": err}).Error("Cannot connect to Graphite")
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(lines.Bytes()); err != nil {
+		log.WithFields(log.Fields{"Address": sink.address, "Error": err}).Error("Cannot send aggregates to Graphite")
+	}
+}
+
+// ParseAggregateSink creates sink by its URL: "statsd" sends aggregates to StatsD client,
+// "graphite://host:port" sends them to Graphite
+func ParseAggregateSink(sinkURL string, client StatsdClientInterface) (AggregateSink, error) {
+	if sinkURL == "statsd" {
+		return NewStatsdSink(client), nil
+	}
+
+	parsedURL, err := url.Parse(sinkURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid aggregate sink URL %q: %v", sinkURL, err)
+	}
+
+	if parsedURL.Scheme != "graphite" {
+		return nil, fmt.Errorf("Unsupported aggregate sink %q", sinkURL)
+	}
+
+	if _, _, err := net.SplitHostPort(parsedURL.Host); err != nil {
+		return nil, fmt.Errorf("Invalid address of aggregate sink URL %q: %v", sinkURL, err)
+	}
+
+	return NewGraphiteSink(parsedURL.Host), nil
+}
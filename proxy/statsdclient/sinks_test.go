@@ -0,0 +1,89 @@
+package statsdclient
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdSinkSendsAggregates(t *testing.T) {
+	server, port := newTestServer(t)
+
+	client := NewClient("127.0.0.1", port, TagFormatInflux)
+	client.Open()
+	defer client.Close()
+
+	require := require.New(t)
+
+	NewStatsdSink(client).Flush(Aggregates{
+		Timers: []TimerAggregate{{
+			Key: "render", Type: "ms", Count: 4, Sum: 10, Mean: 2.5, Upper: 4, Lower: 1,
+			Percentiles: []PercentileAggregate{{99.9, 4}},
+		}},
+		Sets: []SetAggregate{{Key: "visitors", Tags: []Tag{{"env", "prod"}}, Count: 2}},
+	})
+
+	for _, metric := range []string{
+		"render.count:4|c",
+		"render.sum:10|g",
+		"render.mean:2.5|g",
+		"render.upper:4|g",
+		"render.lower:1|g",
+		"render.upper_99_9:4|g",
+		"visitors.count,env=prod:2|g",
+	} {
+		require.Equal(metric, readPacket(t, server))
+	}
+}
+
+func TestGraphiteSinkSendsAggregates(t *testing.T) {
+	require := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		lines, _ := ioutil.ReadAll(conn)
+		received <- string(lines)
+	}()
+
+	sink, err := ParseAggregateSink("graphite://"+listener.Addr().String(), nil)
+	require.NoError(err)
+
+	sink.Flush(Aggregates{
+		Timestamp: time.Unix(1600000000, 0),
+		Counters:  []CounterAggregate{{Key: "clicks", Tags: []Tag{{"env", "prod"}}, Count: 20, Rate: 2}},
+		Gauges:    []GaugeAggregate{{Key: "memory", Value: 512}},
+	})
+
+	require.Equal(
+		"clicks.count;env=prod 20 1600000000\n"+
+			"clicks.rate;env=prod 2 1600000000\n"+
+			"memory 512 1600000000\n",
+		<-received,
+	)
+}
+
+func TestParseAggregateSink(t *testing.T) {
+	require := require.New(t)
+
+	sink, err := ParseAggregateSink("statsd", nil)
+	require.NoError(err)
+	require.IsType(&StatsdSink{}, sink)
+
+	for _, sinkURL := range []string{"graphite", "graphite://graphite.local", "influx://influx.local:8086"} {
+		_, err := ParseAggregateSink(sinkURL, nil)
+		require.Error(err, sinkURL)
+	}
+}
@@ -3,9 +3,14 @@ package statsdclient
 type StatsdClientInterface interface {
 	Open()
 	Close()
-	Count(key string, value int, sampleRate float32)
-	Timing(key string, time int64, sampleRate float32)
-	Gauge(key string, value int)
-	GaugeShift(key string, value int)
-	Set(key string, value int)
+	Count(key string, value int, sampleRate float32, tags []Tag)
+	Timing(key string, time float64, sampleRate float32, tags []Tag)
+	Gauge(key string, value float64, tags []Tag)
+	GaugeShift(key string, value float64, tags []Tag)
+	Set(key string, value string, tags []Tag)
+	Histogram(key string, value float64, sampleRate float32, tags []Tag)
+	Distribution(key string, value float64, sampleRate float32, tags []Tag)
+	Event(event Event)
+	ServiceCheck(check ServiceCheck)
+	Raw(metric string)
 }
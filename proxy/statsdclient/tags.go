@@ -0,0 +1,68 @@
+package statsdclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tag is a key-value pair attached to metric
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// TagFormat defines how tags are encoded in metric line
+type TagFormat string
+
+const (
+	// TagFormatInflux appends tags to key as "key,tag=value:1|c", used by InfluxDB and Telegraf
+	TagFormatInflux TagFormat = "influx"
+	// TagFormatDogStatsD appends tags to metric as "key:1|c|#tag:value"
+	TagFormatDogStatsD TagFormat = "dogstatsd"
+	// TagFormatSignalFx appends tags to key as "key[tag=value]:1|c"
+	TagFormatSignalFx TagFormat = "signalfx"
+	// TagFormatGraphite appends tags to key as "key;tag=value:1|c"
+	TagFormatGraphite TagFormat = "graphite"
+	// TagFormatNone drops tags
+	TagFormatNone TagFormat = "none"
+)
+
+// ParseTagFormat returns tag format by its name
+func ParseTagFormat(name string) (TagFormat, error) {
+	switch format := TagFormat(name); format {
+	case TagFormatInflux, TagFormatDogStatsD, TagFormatSignalFx, TagFormatGraphite, TagFormatNone:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("Unsupported tag format %q", name)
+}
+
+// encode builds metric line from key, value with type and tags
+func (format TagFormat) encode(key string, metricValue string, tags []Tag) string {
+	if len(tags) == 0 {
+		return key + ":" + metricValue
+	}
+
+	switch format {
+	case TagFormatInflux:
+		return key + "," + joinTags(tags, "=", ",") + ":" + metricValue
+	case TagFormatDogStatsD:
+		return key + ":" + metricValue + "|#" + joinTags(tags, ":", ",")
+	case TagFormatSignalFx:
+		return key + "[" + joinTags(tags, "=", ",") + "]:" + metricValue
+	case TagFormatGraphite:
+		return key + ";" + joinTags(tags, "=", ";") + ":" + metricValue
+	}
+
+	return key + ":" + metricValue
+}
+
+// joinTags joins key and value of every tag with pairSeparator, and tags with tagSeparator
+func joinTags(tags []Tag, pairSeparator string, tagSeparator string) string {
+	pairs := make([]string, len(tags))
+	for i, tag := range tags {
+		pairs[i] = tag.Key + pairSeparator + tag.Value
+	}
+
+	return strings.Join(pairs, tagSeparator)
+}
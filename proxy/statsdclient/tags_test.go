@@ -0,0 +1,34 @@
+package statsdclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTagFormat(t *testing.T) {
+	require := require.New(t)
+
+	for _, name := range []string{"influx", "dogstatsd", "signalfx", "graphite", "none"} {
+		format, err := ParseTagFormat(name)
+		require.NoError(err)
+		require.Equal(TagFormat(name), format)
+	}
+
+	_, err := ParseTagFormat("prometheus")
+	require.Error(err)
+}
+
+func TestTagFormatEncode(t *testing.T) {
+	tags := []Tag{{"env", "prod"}, {"app", "web"}}
+
+	require := require.New(t)
+
+	require.Equal("clicks,env=prod,app=web:1|c|@0.5", TagFormatInflux.encode("clicks", "1|c|@0.5", tags))
+	require.Equal("clicks:1|c|@0.5|#env:prod,app:web", TagFormatDogStatsD.encode("clicks", "1|c|@0.5", tags))
+	require.Equal("clicks[env=prod,app=web]:1|c|@0.5", TagFormatSignalFx.encode("clicks", "1|c|@0.5", tags))
+	require.Equal("clicks;env=prod;app=web:1|c|@0.5", TagFormatGraphite.encode("clicks", "1|c|@0.5", tags))
+	require.Equal("clicks:1|c|@0.5", TagFormatNone.encode("clicks", "1|c|@0.5", tags))
+
+	require.Equal("clicks:1|c", TagFormatDogStatsD.encode("clicks", "1|c", nil))
+}
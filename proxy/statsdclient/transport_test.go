@@ -0,0 +1,118 @@
+package statsdclient
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// acceptLines accepts TCP connections and passes every received line to the channel
+func acceptLines(listener net.Listener, lines chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+		}()
+	}
+}
+
+func readLine(t *testing.T, lines <-chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(time.Second):
+		require.FailNow(t, "Line not received")
+		return ""
+	}
+}
+
+func TestTCPClientFramesMetricsWithLineBreaks(t *testing.T) {
+	require := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go acceptLines(listener, lines)
+
+	client := NewTransportClient(TransportTCP, listener.Addr().String(), TagFormatInflux, 0, 0)
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 1, nil)
+	client.Timing("render", 120, 1, nil)
+
+	require.Equal("clicks:1|c", readLine(t, lines))
+	require.Equal("render:120|ms", readLine(t, lines))
+}
+
+func TestTCPClientReconnects(t *testing.T) {
+	require := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	address := listener.Addr().String()
+
+	client := NewTransportClient(TransportTCP, address, TagFormatInflux, 0, 0)
+	client.Open()
+	defer client.Close()
+
+	// server closes the connection
+	conn, err := listener.Accept()
+	require.NoError(err)
+	conn.Close()
+	listener.Close()
+
+	// writes to closed connection fail once peer resets it
+	for i := 0; i < 10 && client.conn != nil; i++ {
+		client.Count("lost", 1, 1, nil)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	listener, err = net.Listen("tcp", address)
+	require.NoError(err)
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go acceptLines(listener, lines)
+
+	client.lastDialTime = time.Time{}
+	client.Count("clicks", 1, 1, nil)
+
+	require.Equal("clicks:1|c", readLine(t, lines))
+}
+
+func TestUnixgramClientSendsMetrics(t *testing.T) {
+	require := require.New(t)
+
+	socketPath := filepath.Join(t.TempDir(), "dsd.socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	require.NoError(err)
+	defer server.Close()
+
+	client := NewTransportClient(TransportUnixgram, socketPath, TagFormatDogStatsD, 0, 0)
+	client.Open()
+	defer client.Close()
+
+	client.Count("clicks", 1, 1, []Tag{{"env", "prod"}})
+
+	buffer := make([]byte, 1024)
+	require.NoError(server.SetReadDeadline(time.Now().Add(time.Second)))
+	length, err := server.Read(buffer)
+	require.NoError(err)
+	require.Equal("clicks:1|c|#env:prod", string(buffer[:length]))
+}